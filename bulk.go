@@ -0,0 +1,310 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/jinzhu/gorm"
+)
+
+// BulkMode is how bulk handlers respond when some of the items of a
+// request fail.
+type BulkMode int
+
+const (
+	// BulkAllOrNothing makes no changes if any item fails, and
+	// responds with the error of the first item to fail (see
+	// BulkItemError). It is the default.
+	BulkAllOrNothing BulkMode = iota
+
+	// BulkPerItem makes the changes of the items that succeed, and
+	// responds with 207 and the result of each item (see
+	// BulkResult).
+	BulkPerItem
+)
+
+// BulkItemError is the error of an item of a bulk request. It is
+// responded to with the mapped response for Err.
+type BulkItemError struct {
+	// Index is the position of the item in the request.
+	Index int
+	Err   error
+}
+
+func (err BulkItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", err.Index, err.Err)
+}
+
+// Unwrap returns Err.
+func (err BulkItemError) Unwrap() error {
+	return err.Err
+}
+
+// BulkResult is the result of an item of a bulk request, as responded
+// with in BulkPerItem mode: its status, and its body as it would be
+// rendered by a single request.
+type BulkResult struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// savepoint is the name of the savepoint that each item of a
+// BulkPerItem request is rolled back to if it fails.
+const savepoint = "resources_bulk_item"
+
+// bulkItem makes the change of an item of a bulk request, returning
+// the changed model, if it is to be responded with.
+type bulkItem func(h *handlers, item json.RawMessage) (DBModel, error)
+
+// bulk calls `fn` for each item in the JSON array of the request body
+// in a single transaction, responding with `status` and the changed
+// models, or with the results of each item in BulkPerItem mode.
+func (h *handlers) bulk(ctx *gin.Context, status int, fn bulkItem) {
+	if err := h.checkOutput(ctx); err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+	serializer, err := h.serializer(ctx)
+	if err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	body, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	items := []json.RawMessage{}
+	if err := json.Unmarshal(body, &items); err != nil {
+		h.abortWithError(ctx, &ValidationError{Fields: []FieldError{{
+			Rule:    "type",
+			Param:   "array",
+			Message: "must be a JSON array",
+		}}})
+		return
+	}
+
+	fields, err := h.outputFields(ctx)
+	if err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	h.inTransaction(ctx, func(h *handlers) {
+		docs := []Document{}
+		results := []BulkResult{}
+		for i, item := range items {
+			if h.bulkMode == BulkPerItem {
+				if err := h.db.Exec("SAVEPOINT " + savepoint).Error; err != nil {
+					h.abortWithError(ctx, err)
+					return
+				}
+			}
+
+			s, err := fn(h, item)
+			if err != nil {
+				err = BulkItemError{Index: i, Err: err}
+				if h.bulkMode != BulkPerItem {
+					h.abortWithError(ctx, err)
+					return
+				}
+
+				if err := h.db.Exec("ROLLBACK TO SAVEPOINT " + savepoint).Error; err != nil {
+					h.abortWithError(ctx, err)
+					return
+				}
+
+				status, body := h.errorResponse(ctx, err)
+				b, err := serializer.Error(status, body)
+				if err != nil {
+					h.abortWithError(ctx, err)
+					return
+				}
+				results = append(results, BulkResult{Status: status, Body: b})
+				continue
+			}
+
+			if h.bulkMode == BulkPerItem {
+				if err := h.db.Exec("RELEASE SAVEPOINT " + savepoint).Error; err != nil {
+					h.abortWithError(ctx, err)
+					return
+				}
+			}
+
+			result := BulkResult{Status: status}
+			if s != nil {
+				doc, err := h.document(ctx, s, fields)
+				if err != nil {
+					h.abortWithError(ctx, err)
+					return
+				}
+				docs = append(docs, doc)
+
+				if result.Body, err = serializer.Single(doc); err != nil {
+					h.abortWithError(ctx, err)
+					return
+				}
+			}
+			results = append(results, result)
+		}
+
+		if h.bulkMode == BulkPerItem {
+			b, err := serializer.MultiStatus(results)
+			if err != nil {
+				h.abortWithError(ctx, err)
+				return
+			}
+			ctx.Data(http.StatusMultiStatus, serializer.ContentType(), b)
+			return
+		}
+
+		if status == http.StatusNoContent {
+			ctx.AbortWithStatus(status)
+			return
+		}
+
+		b, err := serializer.Collection(docs, nil)
+		if err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
+		ctx.Data(status, serializer.ContentType(), b)
+	})
+}
+
+func (h *handlers) bulkPost(ctx *gin.Context, user User, parent DBModel) {
+	SetUser(ctx, user)
+
+	h.bulk(ctx, http.StatusCreated, func(h *handlers, item json.RawMessage) (DBModel, error) {
+		s := h.single()
+		body, err := h.writable(ctx, s, ActionCreate, item)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(body, s); err != nil {
+			return nil, newValidationError(err, s)
+		}
+		if err := binding.Validator.ValidateStruct(s); err != nil {
+			return nil, newValidationError(err, s)
+		}
+
+		if _, err := h.create(ctx, s, user, parent); err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+}
+
+func (h *handlers) bulkPatch(ctx *gin.Context, user User, parent DBModel) {
+	SetUser(ctx, user)
+
+	h.bulk(ctx, http.StatusOK, func(h *handlers, item json.RawMessage) (DBModel, error) {
+		keys := map[string]json.RawMessage{}
+		if err := json.Unmarshal(item, &keys); err != nil {
+			return nil, newValidationError(err, h.single())
+		}
+
+		// The ID key is matched like other fields (see fieldForKey)
+		idField := columnJSONName(h.db, h.single(), h.idColumn())
+		if _, ok := keys[idField]; !ok {
+			for key := range keys {
+				if strings.EqualFold(key, idField) {
+					idField = key
+					break
+				}
+			}
+		}
+		s, err := h.findOwned(ctx, keys[idField], user, parent)
+		if err != nil {
+			return nil, err
+		}
+		if h.policy != nil {
+			if err := h.policy.CanUpdate(user, s, parent); err != nil {
+				return nil, err
+			}
+		}
+
+		delete(keys, idField)
+		body, err := json.Marshal(keys)
+		if err != nil {
+			return nil, err
+		}
+		if body, err = h.writable(ctx, s, ActionUpdate, body); err != nil {
+			return nil, err
+		}
+
+		updates, err := bindPatch(h.db, body, s)
+		if err != nil {
+			return nil, err
+		}
+		if err := h.update(ctx, s, nil, updates); err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+}
+
+func (h *handlers) bulkDelete(ctx *gin.Context, user User, parent DBModel) {
+	SetUser(ctx, user)
+
+	h.bulk(ctx, http.StatusNoContent, func(h *handlers, item json.RawMessage) (DBModel, error) {
+		s, err := h.findOwned(ctx, item, user, parent)
+		if err != nil {
+			return nil, err
+		}
+		if h.policy != nil {
+			if err := h.policy.CanDelete(user, s, parent); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := h.beforeDelete(ctx, s); err != nil {
+			return nil, err
+		}
+		if err := h.db.Delete(s).Error; err != nil {
+			return nil, err
+		}
+		return nil, h.afterDelete(ctx, s)
+	})
+}
+
+// findOwned finds the model with the JSON ID `id`, which must be owned
+// by `user` and have the parent `parent`.
+func (h *handlers) findOwned(ctx *gin.Context, id json.RawMessage, user User, parent DBModel) (DBModel, error) {
+	var v interface{}
+	if err := decodeJSON(id, &v); err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if v == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	where, err := h.whereID(fmt.Sprint(v))
+	if err != nil {
+		return nil, err
+	}
+
+	s := h.single()
+	if err := h.db.Scopes(where).First(s).Error; err != nil {
+		return nil, err
+	}
+
+	expected := h.single()
+	if err := expected.SetOwner(user); err != nil {
+		return nil, err
+	}
+	if err := expected.SetParent(parent); err != nil {
+		return nil, err
+	}
+	if s.OwnerID() != expected.OwnerID() || s.ParentID() != expected.ParentID() {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return s, nil
+}
@@ -0,0 +1,126 @@
+package resources_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theplant/resources"
+)
+
+func TestBulk(t *testing.T) {
+	u, rs := createOwnedResources(t, 2)
+	_, otherRs := createOwnedResources(t, 1)
+
+	perItem := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithBulkMode(resources.BulkPerItem))
+
+	tests := []struct {
+		Resource resources.Resource
+		Method   string
+		Body     string
+		Code     int
+		// Statuses of the items in a 207 response
+		Statuses string
+	}{
+		{res, "POST", `{"Text": "not an array"}`, resources.HTTPStatusUnprocessableEntity, ""},
+		{res, "POST", `[{"Text": "first"}, {"Text": "second"}]`, http.StatusCreated, ""},
+		{res, "POST", `[{"Text": "not created"}, {"Count": 1}]`, resources.HTTPStatusUnprocessableEntity, ""},
+		{res, "PATCH", fmt.Sprintf(`[{"ID": %d, "Text": "not changed"}, {"ID": %d, "Text": "theirs"}]`, rs[0].ID, otherRs[0].ID), http.StatusNotFound, ""},
+		{res, "PATCH", fmt.Sprintf(`[{"ID": %d, "Text": "changed"}, {"ID": %d, "Count": 2}]`, rs[0].ID, rs[1].ID), http.StatusOK, ""},
+		// The ID key is matched case-insensitively, like other fields
+		{res, "PATCH", fmt.Sprintf(`[{"id": %d, "Count": 3}]`, rs[1].ID), http.StatusOK, ""},
+		{res, "DELETE", fmt.Sprintf(`[%d, %d]`, rs[1].ID, otherRs[0].ID), http.StatusNotFound, ""},
+		{perItem, "POST", `[{"Text": "per item"}, {"Count": 1}]`, http.StatusMultiStatus, "201,422"},
+		{perItem, "DELETE", fmt.Sprintf(`[%d, "%d", 0]`, rs[1].ID, otherRs[0].ID), http.StatusMultiStatus, "204,404,404"},
+	}
+
+	for _, test := range tests {
+		r := test.Resource
+		router = gin.New()
+		router.POST("/r", func(ctx *gin.Context) {
+			r.BulkPost(ctx, &u, &u)
+		})
+		router.PATCH("/r", func(ctx *gin.Context) {
+			r.BulkPatch(ctx, &u, &u)
+		})
+		router.DELETE("/r", func(ctx *gin.Context) {
+			r.BulkDelete(ctx, &u, &u)
+		})
+
+		resp := doRequest(t, test.Method, "/r", strings.NewReader(test.Body))
+		if resp.Code != test.Code {
+			t.Fatalf("Error bulk %s %s\nexpected %d, got %d: %v", test.Method, test.Body, test.Code, resp.Code, resp)
+		}
+
+		if test.Statuses != "" {
+			results := []resources.BulkResult{}
+			assertNoErr(json.Unmarshal(resp.Body.Bytes(), &results))
+			statuses := []string{}
+			for _, result := range results {
+				statuses = append(statuses, fmt.Sprint(result.Status))
+			}
+			if got := strings.Join(statuses, ","); got != test.Statuses {
+				t.Fatalf("Wrong item statuses in response to bulk %s %s:\nexpected: '%v'\ngot:      '%v'", test.Method, test.Body, test.Statuses, got)
+			}
+		}
+	}
+
+	created := []Resource{}
+	assertNoErr(db.Where("user_id = ?", u.ID).Order("id").Find(&created).Error)
+	texts := []string{}
+	for _, c := range created {
+		texts = append(texts, c.Text)
+	}
+	if got := strings.Join(texts, ","); got != "changed,first,second,per item" {
+		t.Fatalf("Wrong resources after bulk requests: '%v'", got)
+	}
+
+	theirs := &Resource{}
+	assertNoErr(db.Where("id = ?", otherRs[0].ID).Find(theirs).Error)
+	if theirs.Text != "text" {
+		t.Fatalf("Bulk request changed other owner's resource: '%v'", theirs.Text)
+	}
+
+	// Responses are rendered by the negotiated serializer
+	perItemAPI := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithBulkMode(resources.BulkPerItem),
+		resources.WithSerializers(resources.JSONSerializer{}, resources.JSONAPISerializer{}))
+
+	for _, test := range []struct {
+		Resource    resources.Resource
+		Accept      string
+		Code        int
+		ContentType string
+	}{
+		{perItemAPI, "application/vnd.api+json", http.StatusMultiStatus, "application/vnd.api+json"},
+		{perItemAPI, "text/html", http.StatusNotAcceptable, "application/json; charset=utf-8"},
+		{res, "text/html", http.StatusNotAcceptable, "application/json; charset=utf-8"},
+	} {
+		r := test.Resource
+		router = gin.New()
+		router.POST("/r", func(ctx *gin.Context) {
+			r.BulkPost(ctx, &u, &u)
+		})
+
+		req, err := http.NewRequest("POST", "/r", strings.NewReader(`[{"Text": "negotiated"}, {"Count": 1}]`))
+		assertNoErr(err)
+		req.Header.Set("Accept", test.Accept)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != test.Code {
+			t.Fatalf("Error bulk POST accepting '%s'\nexpected %d, got %d: %v", test.Accept, test.Code, resp.Code, resp)
+		}
+		if contentType := resp.Header().Get("Content-Type"); contentType != test.ContentType {
+			t.Fatalf("Wrong Content-Type in response to bulk POST accepting '%s':\nexpected: '%v'\ngot:      '%v'", test.Accept, test.ContentType, contentType)
+		}
+	}
+}
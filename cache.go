@@ -0,0 +1,133 @@
+package resources
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lastModified returns the `UpdatedAt` time of `s`, or the zero time
+// if it doesn't have one.
+func (h *handlers) lastModified(s interface{}) time.Time {
+	field, ok := h.db.NewScope(s).FieldByName(updatedAtColumn)
+	if !ok {
+		return time.Time{}
+	}
+
+	switch v := field.Field.Interface().(type) {
+	case time.Time:
+		return v
+	case *time.Time:
+		if v != nil {
+			return *v
+		}
+	}
+	return time.Time{}
+}
+
+// collectionLastModified returns the latest `UpdatedAt` time of the
+// models in `items`. It isn't a validator of the collection: deleting
+// a model, or moving it off the page, doesn't make it any earlier.
+func (h *handlers) collectionLastModified(items reflect.Value) time.Time {
+	latest := time.Time{}
+	for i := 0; i < items.Len(); i++ {
+		if t := h.lastModified(modelAt(items, i)); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// collectionETag returns a weak entity tag of a page of a collection,
+// hashed from its JSON body and the total size of the collection, so
+// that it changes when resources are added or deleted as well as
+// when they are updated.
+func collectionETag(body []byte, total int) string {
+	hash := sha1.New()
+	hash.Write(body)
+	hash.Write([]byte(strconv.Itoa(total)))
+	return `W/"` + hex.EncodeToString(hash.Sum(nil)) + `"`
+}
+
+// representationETag returns the entity tag of a representation of
+// `s`, with the content type `contentType` and body `body`: the tag of
+// its version (see etag), followed by a hash of the representation,
+// so that representations with different formats, fields or included
+// associations have different tags. It returns "" if `s` has no tag.
+func (h *handlers) representationETag(s DBModel, contentType string, body []byte) string {
+	etag := h.etag(s)
+	if etag == "" {
+		return ""
+	}
+
+	hash := sha1.New()
+	hash.Write([]byte(contentType))
+	hash.Write(body)
+	return strings.TrimSuffix(etag, `"`) + "-" + hex.EncodeToString(hash.Sum(nil))[:16] + `"`
+}
+
+// respondCached sets the `ETag`, `Last-Modified` and `Cache-Control`
+// headers of the response, and responds with 304 if the request's
+// `If-None-Match` header (or its `If-Modified-Since` header, if
+// `modifiedSince` is true) shows that the client already has the
+// current representation. It returns false if it responded with 304.
+func (h *handlers) respondCached(ctx *gin.Context, etag string, lastModified time.Time, modifiedSince bool) bool {
+	if etag != "" {
+		ctx.Header("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		ctx.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if h.cacheControl != "" {
+		ctx.Header("Cache-Control", h.cacheControl)
+	}
+
+	since := lastModified
+	if !modifiedSince {
+		since = time.Time{}
+	}
+	if notModified(ctx.Request, etag, since) {
+		ctx.AbortWithStatus(http.StatusNotModified)
+		return false
+	}
+	return true
+}
+
+// notModified evaluates the `If-None-Match` and `If-Modified-Since`
+// headers of `req`, as described by RFC 7232. `If-Modified-Since` is
+// ignored when `If-None-Match` is present.
+func notModified(req *http.Request, etag string, lastModified time.Time) bool {
+	if header := req.Header.Get("If-None-Match"); header != "" {
+		if etag == "" {
+			return false
+		}
+		if strings.TrimSpace(header) == "*" {
+			return true
+		}
+
+		// If-None-Match uses weak comparison
+		for _, tag := range strings.Split(header, ",") {
+			if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if header := req.Header.Get("If-Modified-Since"); header != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		if err != nil {
+			return false
+		}
+		// HTTP dates only have second precision
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
@@ -0,0 +1,118 @@
+package resources_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theplant/resources"
+)
+
+func TestConditionalGet(t *testing.T) {
+	r := Resource{Text: "cached"}
+	assertNoErr(db.Save(&r).Error)
+
+	cached := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithCacheControl("private, max-age=60"))
+
+	router = gin.New()
+	router.GET("/r/:id", cached.ProvideModel(cached.Get))
+	path := fmt.Sprintf("/r/%d", r.ID)
+
+	resp := doConditionalRequest(path, "", "")
+	etag := resp.Header().Get("ETag")
+	lastModified := resp.Header().Get("Last-Modified")
+	if resp.Code != http.StatusOK || etag == "" || lastModified == "" {
+		t.Fatalf("Error GETting resource with ETag and Last-Modified\nexpected %d, got %d: %v", http.StatusOK, resp.Code, resp)
+	}
+
+	past := r.UpdatedAt.Add(-time.Hour).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		IfNoneMatch     string
+		IfModifiedSince string
+		Code            int
+	}{
+		{etag, "", http.StatusNotModified},
+		{"W/" + etag, "", http.StatusNotModified},
+		{`"other", ` + etag, "", http.StatusNotModified},
+		{"*", "", http.StatusNotModified},
+		{`"other"`, "", http.StatusOK},
+		{"", lastModified, http.StatusNotModified},
+		{"", past, http.StatusOK},
+		{"", "not a date", http.StatusOK},
+		// If-Modified-Since is ignored when If-None-Match is present
+		{`"other"`, lastModified, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		resp := doConditionalRequest(path, test.IfNoneMatch, test.IfModifiedSince)
+		if resp.Code != test.Code {
+			t.Fatalf("Error GETting with If-None-Match '%s', If-Modified-Since '%s'\nexpected %d, got %d: %v", test.IfNoneMatch, test.IfModifiedSince, test.Code, resp.Code, resp)
+		}
+		if resp.Code == http.StatusNotModified && resp.Body.Len() != 0 {
+			t.Fatalf("Unexpected body in 304 response: %s", resp.Body.String())
+		}
+		if cacheControl := resp.Header().Get("Cache-Control"); cacheControl != "private, max-age=60" {
+			t.Fatalf("Wrong Cache-Control header:\nexpected: '%v'\ngot:      '%v'", "private, max-age=60", cacheControl)
+		}
+	}
+}
+
+func TestConditionalCollection(t *testing.T) {
+	u, rs := createOwnedResources(t, 3)
+
+	router = gin.New()
+	router.GET("/test", func(ctx *gin.Context) {
+		res.Collection(ctx, &u)
+	})
+
+	resp := doConditionalRequest("/test", "", "")
+	etag := resp.Header().Get("ETag")
+	if resp.Code != http.StatusOK || etag == "" || resp.Header().Get("Last-Modified") == "" {
+		t.Fatalf("Error GETting collection with ETag and Last-Modified\nexpected %d, got %d: %v", http.StatusOK, resp.Code, resp)
+	}
+	if len(unmarshalCollection(t, resp)) != 3 {
+		t.Fatalf("Wrong collection returned: %v", resp)
+	}
+
+	if resp := doConditionalRequest("/test", etag, ""); resp.Code != http.StatusNotModified {
+		t.Fatalf("Error GETting unchanged collection\nexpected %d, got %d: %v", http.StatusNotModified, resp.Code, resp)
+	}
+
+	assertNoErr(db.Model(&rs[1]).Update("text", "changed").Error)
+	if resp := doConditionalRequest("/test", etag, ""); resp.Code != http.StatusOK {
+		t.Fatalf("Error GETting changed collection\nexpected %d, got %d: %v", http.StatusOK, resp.Code, resp)
+	}
+
+	resp = doConditionalRequest("/test", "", "")
+	etag = resp.Header().Get("ETag")
+	lastModified := resp.Header().Get("Last-Modified")
+	assertNoErr(db.Delete(&rs[2]).Error)
+	if resp := doConditionalRequest("/test", etag, ""); resp.Code != http.StatusOK {
+		t.Fatalf("Error GETting collection after delete\nexpected %d, got %d: %v", http.StatusOK, resp.Code, resp)
+	}
+	// Deleting doesn't change Last-Modified, so it isn't used
+	if resp := doConditionalRequest("/test", "", lastModified); resp.Code != http.StatusOK {
+		t.Fatalf("Error GETting collection modified since delete\nexpected %d, got %d: %v", http.StatusOK, resp.Code, resp)
+	}
+}
+
+func doConditionalRequest(path, ifNoneMatch, ifModifiedSince string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", path, nil)
+	assertNoErr(err)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	if ifModifiedSince != "" {
+		req.Header.Set("If-Modified-Since", ifModifiedSince)
+	}
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
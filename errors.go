@@ -0,0 +1,174 @@
+package resources
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// ErrInternal is the error responded with for errors that aren't
+// handled by any ErrorMapper, so that the details of database errors
+// aren't leaked to clients.
+var ErrInternal = errors.New("internal server error")
+
+// ErrForbidden is returned when a user isn't allowed to access a
+// resource. Handlers respond to it with 403.
+var ErrForbidden = errors.New("forbidden")
+
+// RequestIDHeader is the request header used by default to find a
+// request (or correlation) ID to include in error responses.
+const RequestIDHeader = "X-Request-ID"
+
+// ErrorSink is given every error that a handler responds to, after
+// the error has been mapped to a response.
+type ErrorSink func(*gin.Context, error)
+
+// GinErrorSink is the default ErrorSink. It adds errors to the
+// request context with `ctx.Error`, so that Gin error middleware can
+// log them.
+func GinErrorSink(ctx *gin.Context, err error) {
+	ctx.Error(err)
+}
+
+// ErrorMapper turns errors from the database (or anything else a
+// handler calls) into HTTP responses.
+type ErrorMapper interface {
+	// MapError returns the HTTP status and JSON body to respond to
+	// `err` with. `ok` is false if the mapper doesn't handle `err`.
+	MapError(err error) (status int, body interface{}, ok bool)
+}
+
+// ErrorMapperFunc adapts a function to the ErrorMapper interface.
+type ErrorMapperFunc func(error) (int, interface{}, bool)
+
+// MapError calls `fn`.
+func (fn ErrorMapperFunc) MapError(err error) (int, interface{}, bool) {
+	return fn(err)
+}
+
+// ErrorMappers is an ErrorMapper that tries each of its mappers in
+// turn, responding with the first one that handles the error.
+type ErrorMappers []ErrorMapper
+
+// MapError calls each mapper until one handles `err`.
+func (mappers ErrorMappers) MapError(err error) (int, interface{}, bool) {
+	for _, mapper := range mappers {
+		if mapper == nil {
+			continue
+		}
+		if status, body, ok := mapper.MapError(err); ok {
+			return status, body, true
+		}
+	}
+	return 0, nil, false
+}
+
+// MapErrorIs maps errors that match `target` (via `errors.Is`) to
+// `status`, with the error message as the body.
+func MapErrorIs(target error, status int) ErrorMapper {
+	return ErrorMapperFunc(func(err error) (int, interface{}, bool) {
+		if errors.Is(err, target) {
+			return status, errToJSON(err), true
+		}
+		return 0, nil, false
+	})
+}
+
+// MapErrorAs maps errors that have the same type as `example`
+// somewhere in their chain (via `errors.As`) to `status`, with the
+// error message as the body. For example:
+//
+//	MapErrorAs(&pq.Error{}, HTTPStatusUnprocessableEntity)
+func MapErrorAs(example error, status int) ErrorMapper {
+	return MapErrorType(reflect.TypeOf(example), status)
+}
+
+// MapErrorType is like MapErrorAs, but takes the type of the error.
+// Types that aren't errors (eg. `pq.Error`, whose pointer is the
+// error) are compared with the type of each error in the chain.
+func MapErrorType(t reflect.Type, status int) ErrorMapper {
+	return ErrorMapperFunc(func(err error) (int, interface{}, bool) {
+		if t != nil && hasErrorType(err, t) {
+			return status, errToJSON(err), true
+		}
+		return 0, nil, false
+	})
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// hasErrorType is true if `err`, or an error it wraps, has type `t`.
+func hasErrorType(err error, t reflect.Type) bool {
+	// errors.As panics for targets that can't hold an error
+	if t.Kind() == reflect.Interface || t.Implements(errorType) {
+		return errors.As(err, reflect.New(t).Interface())
+	}
+	for ; err != nil; err = errors.Unwrap(err) {
+		if reflect.TypeOf(err) == t {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultErrorMapper is used after a resource's own error mapper. It
+// maps missing records to 404, ErrForbidden to 403, ErrNotAcceptable
+// to 406, invalid requests to 400 or HTTPStatusUnprocessableEntity,
+// and the deprecated AcceptableError to HTTPStatusUnprocessableEntity.
+var defaultErrorMapper = ErrorMappers{
+	MapErrorIs(gorm.ErrRecordNotFound, http.StatusNotFound),
+	MapErrorIs(ErrForbidden, http.StatusForbidden),
+	MapErrorIs(ErrNotAcceptable, http.StatusNotAcceptable),
+	ErrorMapperFunc(func(err error) (int, interface{}, bool) {
+		var errs FilterErrors
+		if errors.As(err, &errs) {
+			return http.StatusBadRequest, gin.H{"error": err.Error(), "filters": errs}, true
+		}
+		return 0, nil, false
+	}),
+	MapErrorAs(SortError{}, http.StatusBadRequest),
+	MapErrorAs(FieldsError{}, http.StatusBadRequest),
+	MapErrorAs(IncludeError{}, http.StatusBadRequest),
+	MapErrorIs(ErrInvalidLimit, http.StatusBadRequest),
+	MapErrorIs(ErrInvalidDeleted, http.StatusBadRequest),
+	MapErrorIs(ErrInvalidOffset, http.StatusBadRequest),
+	MapErrorIs(ErrInvalidCursor, http.StatusBadRequest),
+	MapErrorIs(ErrCursorWithOffset, http.StatusBadRequest),
+	MapErrorIs(ErrCursorWithSort, http.StatusBadRequest),
+	MapErrorIs(ErrCursorUnsupported, http.StatusBadRequest),
+	ErrorMapperFunc(func(err error) (int, interface{}, bool) {
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			return HTTPStatusUnprocessableEntity, gin.H{"error": err.Error(), "fields": verr.Fields}, true
+		}
+		return 0, nil, false
+	}),
+	MapErrorIs(ErrRequestMissingAttrs, HTTPStatusUnprocessableEntity),
+	MapErrorIs(ErrPatchTestFailed, http.StatusConflict),
+	MapErrorAs(&PatchError{}, HTTPStatusUnprocessableEntity),
+	MapErrorIs(ErrPreconditionFailed, http.StatusPreconditionFailed),
+	MapErrorIs(ErrPreconditionRequired, http.StatusPreconditionRequired),
+	ErrorMapperFunc(func(err error) (int, interface{}, bool) {
+		return MapErrorType(AcceptableError, HTTPStatusUnprocessableEntity).MapError(err)
+	}),
+}
+
+// handlersKey is the context key of the handlers of the resource that
+// provided the request's model (see ProvideModel).
+const handlersKey = "resources.handlers"
+
+// abortWithError responds to `err` as the handlers of the resource
+// that provided the request's model would, so that processors such as
+// RequireOwner respond with the resource's ErrorMapper, ErrorSink and
+// serializers. Requests without a model provided by a resource get
+// the default options.
+func abortWithError(ctx *gin.Context, err error) {
+	h, ok := ctx.Value(handlersKey).(*handlers)
+	if !ok {
+		h = &handlers{options: newOptions(nil, nil)}
+	}
+	h.abortWithError(ctx, err)
+}
@@ -0,0 +1,151 @@
+package resources_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+
+	"github.com/theplant/resources"
+)
+
+type validationError struct {
+	msg string
+}
+
+func (err *validationError) Error() string {
+	return err.msg
+}
+
+var errConflict = errors.New("conflict")
+
+func TestErrorMapper(t *testing.T) {
+	u := User{}
+	assertNoErr(db.Save(&u).Error)
+
+	mapped := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithErrorMapper(resources.MapErrorAs(&validationError{}, resources.HTTPStatusUnprocessableEntity)),
+		resources.WithErrorMapper(resources.MapErrorIs(errConflict, http.StatusConflict)),
+		resources.WithErrorMapper(resources.ErrorMapperFunc(func(err error) (int, interface{}, bool) {
+			if err.Error() == "teapot" {
+				return http.StatusTeapot, map[string]string{"tea": "pot"}, true
+			}
+			return 0, nil, false
+		})))
+
+	tests := []struct {
+		Err  error
+		Code int
+		Body string
+	}{
+		{&validationError{"invalid"}, resources.HTTPStatusUnprocessableEntity, `{"error":"invalid"}`},
+		{fmt.Errorf("saving: %w", &validationError{"invalid"}), resources.HTTPStatusUnprocessableEntity, `{"error":"saving: invalid"}`},
+		{fmt.Errorf("saving: %w", errConflict), http.StatusConflict, `{"error":"saving: conflict"}`},
+		{errors.New("teapot"), http.StatusTeapot, `{"tea":"pot"}`},
+	}
+
+	for _, test := range tests {
+		r := Resource{}
+		assertNoErr(db.Save(&r).Error)
+
+		resourceError = test.Err
+
+		post := mountOwnerParentHandler(t, &u, &u, mapped.Post)(postBody(t, struct{ Text string }{"text"}))
+		patch := mountResourceHandler(t, &r, mapped.Patch)(postBody(t, struct{ Text string }{"text"}))
+
+		for _, resp := range []struct {
+			Method string
+			Code   int
+			Body   string
+		}{{"POST", post.Code, body(t, post)}, {"PATCH", patch.Code, body(t, patch)}} {
+			if resp.Code != test.Code {
+				t.Fatalf("Error %sing resource with %v\nexpected %d, got %d", resp.Method, test.Err, test.Code, resp.Code)
+			}
+			if resp.Body != test.Body {
+				t.Fatalf("Response differs for %s with %v:\nexpected: '%v'\ngot:      '%v'", resp.Method, test.Err, test.Body, resp.Body)
+			}
+		}
+
+		clearResourceErrors()
+	}
+}
+
+func TestAcceptableErrorNotAnError(t *testing.T) {
+	u := User{}
+	assertNoErr(db.Save(&u).Error)
+
+	// Only pointers to validationError are errors
+	resources.AcceptableError = reflect.TypeOf(validationError{})
+	resourceError = &validationError{"invalid"}
+	defer clearResourceErrors()
+
+	resp := mountOwnerParentHandler(t, &u, &u, res.Post)(postBody(t, struct{ Text string }{"text"}))
+	if resp.Code != http.StatusInternalServerError {
+		t.Fatalf("Error POSTing resource with non-error AcceptableError\nexpected %d, got %d: %v", http.StatusInternalServerError, resp.Code, resp)
+	}
+}
+
+func TestErrorSinkAndRequestID(t *testing.T) {
+	u := User{}
+	assertNoErr(db.Save(&u).Error)
+
+	sunk := []error{}
+	sinking := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithErrorSink(func(ctx *gin.Context, err error) {
+			sunk = append(sunk, err)
+		}))
+
+	resourceError = errors.New("an error")
+	defer clearResourceErrors()
+
+	router = gin.New()
+	router.POST("/test", func(ctx *gin.Context) {
+		sinking.Post(ctx, &u, &u)
+	})
+
+	req, err := http.NewRequest("POST", "/test", postBody(t, struct{ Text string }{"text"}))
+	assertNoErr(err)
+	req.Header.Set(resources.RequestIDHeader, "abc-123")
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusInternalServerError {
+		t.Fatalf("Error POSTing resource\nexpected %d, got %d: %v", http.StatusInternalServerError, resp.Code, resp)
+	}
+
+	expected := `{"error":"internal server error","request_id":"abc-123"}`
+	if result := body(t, resp); result != expected {
+		t.Fatalf("Response differs:\nexpected: '%v'\ngot:      '%v'", expected, result)
+	}
+
+	if len(sunk) != 1 || sunk[0] != resourceError {
+		t.Fatalf("Error didn't reach sink\nexpected: '%v'\ngot:      '%v'", []error{resourceError}, sunk)
+	}
+}
+
+func TestGinErrorSink(t *testing.T) {
+	var errs []*gin.Error
+
+	router = gin.New()
+	router.GET("/r/:id", func(ctx *gin.Context) {
+		res.ProvideModel(func(*gin.Context, resources.DBModel) {})(ctx)
+		errs = ctx.Errors
+	})
+
+	resp := doRequest(t, "GET", "/r/not-an-id", nil)
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("Error finding resource\nexpected %d, got %d: %v", http.StatusNotFound, resp.Code, resp)
+	}
+
+	if len(errs) != 1 || errs[0].Err != gorm.ErrRecordNotFound {
+		t.Fatalf("Error wasn't added to context\nexpected: '%v'\ngot:      '%v'", gorm.ErrRecordNotFound, errs)
+	}
+}
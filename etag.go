@@ -0,0 +1,188 @@
+package resources
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// ErrPreconditionFailed is returned when the `If-Match` header of a
+// request doesn't match the current entity tag of the resource.
+// Handlers respond to it with 412.
+var ErrPreconditionFailed = errors.New("resource has been modified")
+
+// ErrPreconditionRequired is returned when a resource created with
+// WithRequirePreconditions is changed without an `If-Match` header.
+// Handlers respond to it with 428.
+var ErrPreconditionRequired = errors.New("request must have an If-Match header")
+
+// updatedAtColumn is used for entity tags of resources without a
+// version column.
+const updatedAtColumn = "updated_at"
+
+// etag returns the entity tag of the version of `s`, or "" if it
+// doesn't have a version column or (non-zero) `UpdatedAt` time.
+// Responses tag each representation of the version separately (see
+// representationETag), but `If-Match` only compares versions.
+//
+// Tags of `UpdatedAt` times are in microseconds, as that is the
+// precision Postgres stores timestamps with.
+func (h *handlers) etag(s DBModel) string {
+	field, ok := h.db.NewScope(s).FieldByName(h.etagColumn())
+	if !ok {
+		return ""
+	}
+
+	switch v := field.Field.Interface().(type) {
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return strconv.Quote(strconv.FormatInt(v.UnixNano()/int64(time.Microsecond), 10))
+	case *time.Time:
+		if v == nil || v.IsZero() {
+			return ""
+		}
+		return strconv.Quote(strconv.FormatInt(v.UnixNano()/int64(time.Microsecond), 10))
+	default:
+		return strconv.Quote(fmt.Sprint(v))
+	}
+}
+
+func (h *handlers) etagColumn() string {
+	if h.versionColumn != "" {
+		return h.versionColumn
+	}
+	return updatedAtColumn
+}
+
+// ifMatch converts the `If-Match` header of the request into a scope
+// that only matches rows with one of the listed entity tags, so that
+// the check happens in the same statement as the write. It returns a
+// nil scope if the request has no `If-Match` header.
+func (h *handlers) ifMatch(ctx *gin.Context) (func(*gorm.DB) *gorm.DB, error) {
+	header := strings.TrimSpace(ctx.Request.Header.Get("If-Match"))
+	if header == "" {
+		if h.requirePreconditions {
+			return nil, ErrPreconditionRequired
+		}
+		return nil, nil
+	}
+
+	if header == "*" {
+		return func(db *gorm.DB) *gorm.DB { return db }, nil
+	}
+
+	column := h.db.Dialect().Quote(h.etagColumn())
+	conditions := []string{}
+	args := []interface{}{}
+	for _, tag := range strings.Split(header, ",") {
+		// Weak tags never match, as If-Match uses strong comparison
+		value, err := strconv.Unquote(strings.TrimSpace(tag))
+		if err != nil {
+			continue
+		}
+		// Tags of every representation of a version match it
+		if i := strings.LastIndex(value, "-"); i > 0 {
+			value = value[:i]
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if h.versionColumn != "" {
+			conditions = append(conditions, column+" = ?")
+			args = append(args, n)
+		} else {
+			t := time.Unix(0, n*int64(time.Microsecond))
+			conditions = append(conditions, "("+column+" >= ? AND "+column+" < ?)")
+			args = append(args, t, t.Add(time.Microsecond))
+		}
+	}
+
+	if len(conditions) == 0 {
+		return func(db *gorm.DB) *gorm.DB { return db.Where("1 = 0") }, nil
+	}
+	where := strings.Join(conditions, " OR ")
+	return func(db *gorm.DB) *gorm.DB { return db.Where(where, args...) }, nil
+}
+
+// update writes `updates` to the row of `s`, if the row matches the
+// `ifMatch` scope, and increments the version column (if any). `s`
+// is updated with the new values, including its new entity tag. The
+// update hooks are called either side of the write.
+func (h *handlers) update(ctx *gin.Context, s DBModel, ifMatch func(*gorm.DB) *gorm.DB, updates map[string]interface{}) error {
+	updates, err := h.beforeUpdate(ctx, s, updates)
+	if err != nil {
+		return err
+	}
+	old := copyModel(s)
+
+	if len(updates) > 0 && h.versionColumn != "" {
+		updates[h.versionColumn] = gorm.Expr(h.db.Dialect().Quote(h.versionColumn) + " + 1")
+	}
+
+	var affected int64
+	if len(updates) > 0 {
+		// Associations preloaded with `include` aren't written back
+		result := h.db.Model(s).Set("gorm:association_autoupdate", false).Set("gorm:association_autocreate", false).Scopes(scopes(ifMatch)...).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		affected = result.RowsAffected
+	}
+
+	if affected == 0 {
+		// Nothing was written, either because the row didn't match
+		// or because the DB only counts changed rows
+		err = h.checkMatch(s, ifMatch)
+	} else {
+		err = h.reloadVersion(s)
+	}
+	if err != nil {
+		return err
+	}
+	return h.afterUpdate(ctx, old, s)
+}
+
+// checkMatch returns ErrPreconditionFailed if the row of `s` doesn't
+// match the `ifMatch` scope.
+func (h *handlers) checkMatch(s DBModel, ifMatch func(*gorm.DB) *gorm.DB) error {
+	if ifMatch == nil {
+		return nil
+	}
+
+	var n int
+	if err := h.db.Model(s).Scopes(ifMatch).Count(&n).Error; err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// reloadVersion reads the version column (or `UpdatedAt` time) of
+// `s` back from the DB, as the DB may store it differently (eg. with
+// less precision) to how it was written.
+func (h *handlers) reloadVersion(s DBModel) error {
+	field, ok := h.db.NewScope(s).FieldByName(h.etagColumn())
+	if !ok {
+		return nil
+	}
+
+	return h.db.Model(s).Select(h.db.Dialect().Quote(field.DBName)).Row().Scan(field.Field.Addr().Interface())
+}
+
+func scopes(scope func(*gorm.DB) *gorm.DB) []func(*gorm.DB) *gorm.DB {
+	if scope == nil {
+		return nil
+	}
+	return []func(*gorm.DB) *gorm.DB{scope}
+}
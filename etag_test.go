@@ -0,0 +1,210 @@
+package resources_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theplant/resources"
+)
+
+type versionedResource struct {
+	Resource
+
+	Version uint
+}
+
+func TestETag(t *testing.T) {
+	r := Resource{Text: "original"}
+	assertNoErr(db.Save(&r).Error)
+
+	req := mountETagHandlers(t, res)
+	path := fmt.Sprintf("/r/%d", r.ID)
+
+	resp := req("GET", path, "", "")
+	etag := resp.Header().Get("ETag")
+	if resp.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Error GETting resource with ETag\nexpected %d, got %d: %v", http.StatusOK, resp.Code, resp)
+	}
+
+	resp = req("PATCH", path, etag, `{"Text": "first"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Error PATCHing resource with current ETag\nexpected %d, got %d: %v", http.StatusOK, resp.Code, resp)
+	}
+	updated := resp.Header().Get("ETag")
+	if updated == "" || updated == etag {
+		t.Fatalf("ETag not changed by PATCH: '%v'", updated)
+	}
+
+	if get := req("GET", path, "", "").Header().Get("ETag"); get != updated {
+		t.Fatalf("ETag of PATCH response differs from GET:\nexpected: '%v'\ngot:      '%v'", get, updated)
+	}
+
+	tests := []struct {
+		Method  string
+		IfMatch string
+		Body    string
+		Code    int
+	}{
+		{"PATCH", etag, `{"Text": "second"}`, http.StatusPreconditionFailed},
+		{"PATCH", `W/` + updated, `{"Text": "second"}`, http.StatusPreconditionFailed},
+		{"PATCH", `"not-a-tag"`, `{"Text": "second"}`, http.StatusPreconditionFailed},
+		{"PATCH", `"1", ` + updated, `{"Text": "first"}`, http.StatusOK},
+		{"PATCH", "*", `{"Text": "first"}`, http.StatusOK},
+		{"DELETE", etag, "", http.StatusPreconditionFailed},
+	}
+
+	for _, test := range tests {
+		resp := req(test.Method, path, test.IfMatch, test.Body)
+		if resp.Code != test.Code {
+			t.Fatalf("Error %s with If-Match %s\nexpected %d, got %d: %v", test.Method, test.IfMatch, test.Code, resp.Code, resp)
+		}
+		if resp.Code == http.StatusOK {
+			updated = resp.Header().Get("ETag")
+		}
+	}
+
+	reloaded := &Resource{}
+	assertNoErr(db.Where("id = ?", r.ID).Find(reloaded).Error)
+	if reloaded.Text != "first" {
+		t.Fatalf("Resource changed by request with stale ETag: '%v'", reloaded.Text)
+	}
+
+	if resp := req("DELETE", path, updated, ""); resp.Code != http.StatusNoContent {
+		t.Fatalf("Error DELETEing resource with current ETag\nexpected %d, got %d: %v", http.StatusNoContent, resp.Code, resp)
+	}
+}
+
+func TestVersionColumn(t *testing.T) {
+	assertNoErr(db.AutoMigrate(&versionedResource{}).Error)
+
+	r := versionedResource{Resource: Resource{Text: "original"}}
+	assertNoErr(db.Save(&r).Error)
+
+	versioned := resources.NewWithOptions(db,
+		func() resources.DBModel { return &versionedResource{} },
+		resources.WithVersionColumn("version"))
+
+	req := mountETagHandlers(t, versioned)
+	path := fmt.Sprintf("/r/%d", r.ID)
+
+	etag := req("GET", path, "", "").Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"0-`) {
+		t.Fatalf("Wrong ETag for version column:\nexpected: '%v...'\ngot:      '%v'", `"0-`, etag)
+	}
+
+	// Two clients that both GOT version 0
+	first := req("PATCH", path, etag, `{"Text": "first"}`)
+	second := req("PATCH", path, `"0"`, `{"Text": "second"}`)
+
+	if first.Code != http.StatusOK || !strings.HasPrefix(first.Header().Get("ETag"), `"1-`) {
+		t.Fatalf("Error PATCHing versioned resource\nexpected %d with ETag '\"1-...', got %d: %v", http.StatusOK, first.Code, first)
+	}
+	if second.Code != http.StatusPreconditionFailed {
+		t.Fatalf("Error PATCHing versioned resource with stale version\nexpected %d, got %d: %v", http.StatusPreconditionFailed, second.Code, second)
+	}
+
+	reloaded := &versionedResource{}
+	assertNoErr(db.Where("id = ?", r.ID).Find(reloaded).Error)
+	if reloaded.Text != "first" || reloaded.Version != 1 {
+		t.Fatalf("Wrong result of concurrent PATCHes: '%v', version %v", reloaded.Text, reloaded.Version)
+	}
+}
+
+func TestRequirePreconditions(t *testing.T) {
+	r := Resource{Text: "original"}
+	assertNoErr(db.Save(&r).Error)
+
+	required := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithRequirePreconditions())
+
+	req := mountETagHandlers(t, required)
+	path := fmt.Sprintf("/r/%d", r.ID)
+
+	for _, method := range []string{"PATCH", "DELETE"} {
+		if resp := req(method, path, "", `{"Text": "changed"}`); resp.Code != http.StatusPreconditionRequired {
+			t.Fatalf("Error %s without If-Match\nexpected %d, got %d: %v", method, http.StatusPreconditionRequired, resp.Code, resp)
+		}
+	}
+
+	etag := req("GET", path, "", "").Header().Get("ETag")
+	if resp := req("PATCH", path, etag, `{"Text": "changed"}`); resp.Code != http.StatusOK {
+		t.Fatalf("Error PATCHing with If-Match\nexpected %d, got %d: %v", http.StatusOK, resp.Code, resp)
+	}
+}
+
+func TestETagRepresentations(t *testing.T) {
+	r := versionedResource{Resource: Resource{Text: "original"}}
+	assertNoErr(db.Save(&r).Error)
+
+	versioned := resources.NewWithOptions(db,
+		func() resources.DBModel { return &versionedResource{} },
+		resources.WithVersionColumn("version"),
+		resources.WithSerializers(resources.JSONSerializer{}, resources.JSONAPISerializer{}))
+
+	req := mountETagHandlers(t, versioned)
+	path := fmt.Sprintf("/r/%d", r.ID)
+
+	get := func(path, accept, ifNoneMatch string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", path, nil)
+		assertNoErr(err)
+		req.Header.Set("Accept", accept)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// Each representation of the version has its own tag
+	etags := map[string]bool{}
+	for _, test := range []struct{ Path, Accept string }{
+		{path, "application/json"},
+		{path, "application/vnd.api+json"},
+		{path + "?fields=Text", "application/json"},
+	} {
+		etag := get(test.Path, test.Accept, "").Header().Get("ETag")
+		if !strings.HasPrefix(etag, `"0-`) || etags[etag] {
+			t.Fatalf("Wrong ETag for %s as %s: '%v' (others: %v)", test.Path, test.Accept, etag, etags)
+		}
+		etags[etag] = true
+	}
+
+	jsonTag := get(path, "application/json", "").Header().Get("ETag")
+	if resp := get(path, "application/json", jsonTag); resp.Code != http.StatusNotModified {
+		t.Fatalf("Error GETting with current ETag\nexpected %d, got %d: %v", http.StatusNotModified, resp.Code, resp)
+	}
+	if resp := get(path, "application/vnd.api+json", jsonTag); resp.Code != http.StatusOK {
+		t.Fatalf("Error GETting another representation with ETag\nexpected %d, got %d: %v", http.StatusOK, resp.Code, resp)
+	}
+
+	// ...but any of them match the version for updates
+	apiTag := get(path, "application/vnd.api+json", "").Header().Get("ETag")
+	if resp := req("PATCH", path, apiTag, `{"Text": "changed"}`); resp.Code != http.StatusOK {
+		t.Fatalf("Error PATCHing with ETag of another representation\nexpected %d, got %d: %v", http.StatusOK, resp.Code, resp)
+	}
+}
+
+func mountETagHandlers(t *testing.T, r resources.Resource) func(method, path, ifMatch, body string) *httptest.ResponseRecorder {
+	router = gin.New()
+	router.GET("/r/:id", r.ProvideModel(r.Get))
+	router.PATCH("/r/:id", r.ProvideModel(r.Patch))
+	router.DELETE("/r/:id", r.ProvideModel(r.Delete))
+
+	return func(method, path, ifMatch, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		assertNoErr(err)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+}
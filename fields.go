@@ -0,0 +1,161 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// modelField is a field of a DB model that is stored in a DB column,
+// along with the name used for it in JSON.
+type modelField struct {
+	*gorm.StructField
+
+	jsonName string
+}
+
+// columnFields returns the fields of `model` that are stored in DB
+// columns and included in JSON, keyed by their JSON names.
+func columnFields(db *gorm.DB, model interface{}) map[string]modelField {
+	fields := map[string]modelField{}
+	for _, f := range db.NewScope(model).GetModelStruct().StructFields {
+		if !f.IsNormal || f.IsIgnored {
+			continue
+		}
+
+		name, ok := jsonFieldName(f.Struct)
+		if !ok {
+			continue
+		}
+		fields[name] = modelField{StructField: f, jsonName: name}
+	}
+	return fields
+}
+
+// fieldForKey returns the field of `fields` that encoding/json binds
+// the JSON object key `key` to: the field with the JSON name `key`,
+// or else one whose name matches it case-insensitively.
+func fieldForKey(fields map[string]modelField, key string) (modelField, bool) {
+	if f, ok := fields[key]; ok {
+		return f, true
+	}
+	for name, f := range fields {
+		if strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	return modelField{}, false
+}
+
+// value returns the value of the field in `model`.
+func (f modelField) value(model interface{}) interface{} {
+	return reflect.Indirect(reflect.ValueOf(model)).FieldByName(f.Name).Interface()
+}
+
+// zero sets the field in `model` to its zero value.
+func (f modelField) zero(model interface{}) {
+	v := reflect.Indirect(reflect.ValueOf(model)).FieldByName(f.Name)
+	v.Set(reflect.Zero(v.Type()))
+}
+
+// copyModel returns a shallow copy of `model`, which must be a pointer
+// to a struct.
+func copyModel(model DBModel) DBModel {
+	v := reflect.ValueOf(model).Elem()
+	c := reflect.New(v.Type())
+	c.Elem().Set(v)
+	return c.Interface().(DBModel)
+}
+
+// replacement returns the DB columns (and their values) of every field
+// of `s`, apart from the primary key and Gorm's timestamps, for
+// replacing an existing row with `s`.
+func replacement(db *gorm.DB, s DBModel) map[string]interface{} {
+	columns := map[string]interface{}{}
+	for _, f := range columnFields(db, s) {
+		if f.IsPrimaryKey || f.DBName == "created_at" || f.DBName == "updated_at" || f.DBName == "deleted_at" {
+			continue
+		}
+		columns[f.DBName] = f.value(s)
+	}
+	return columns
+}
+
+// setColumn sets the field of `s` with the DB column `column` to
+// `id`, as given in a URL.
+func setColumn(db *gorm.DB, s DBModel, column string, id string) error {
+	field, ok := db.NewScope(s).FieldByName(column)
+	if !ok {
+		return fmt.Errorf("%T has no column %q", s, column)
+	}
+
+	v := field.Field
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(id)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	default:
+		return fmt.Errorf("can't set column of type %v", v.Type())
+	}
+	return nil
+}
+
+// selectFields returns the JSON encoding of `obj`, which must encode
+// to an object or an array of objects, as generic JSON values with
+// only the given fields.
+func selectFields(obj interface{}, fields []string) (interface{}, error) {
+	encoded, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if err := decodeJSON(encoded, &v); err != nil {
+		return nil, err
+	}
+
+	keep := func(o interface{}) {
+		if m, ok := o.(map[string]interface{}); ok {
+			for k := range m {
+				if !contains(fields, k) {
+					delete(m, k)
+				}
+			}
+		}
+	}
+
+	if items, ok := v.([]interface{}); ok {
+		for _, item := range items {
+			keep(item)
+		}
+	} else {
+		keep(v)
+	}
+	return v, nil
+}
+
+// columnJSONName returns the JSON name of the field of `model` with
+// the DB column `column`.
+func columnJSONName(db *gorm.DB, model interface{}, column string) string {
+	for name, f := range jsonFields(db, model) {
+		if f.DBName == column {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,233 @@
+package resources
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// FilterOperator is a comparison that can be used to filter a
+// collection, given in the query string as `column[operator]=value`.
+// A bare `column=value` uses FilterEq.
+type FilterOperator string
+
+// Filter operators supported by Collection handlers.
+const (
+	FilterEq       FilterOperator = "eq"
+	FilterNe       FilterOperator = "ne"
+	FilterLt       FilterOperator = "lt"
+	FilterLte      FilterOperator = "lte"
+	FilterGt       FilterOperator = "gt"
+	FilterGte      FilterOperator = "gte"
+	FilterContains FilterOperator = "contains"
+	FilterPrefix   FilterOperator = "prefix"
+	// FilterIn matches any of a comma-separated list of values.
+	FilterIn FilterOperator = "in"
+	// FilterNull matches NULL columns for `true`, and non-NULL
+	// columns for `false`.
+	FilterNull FilterOperator = "null"
+)
+
+var (
+	// FilterComparable are the operators that make sense for numbers
+	// and times.
+	FilterComparable = []FilterOperator{FilterEq, FilterNe, FilterLt, FilterLte, FilterGt, FilterGte, FilterIn}
+
+	// FilterText are the operators that make sense for strings.
+	FilterText = []FilterOperator{FilterEq, FilterNe, FilterContains, FilterPrefix, FilterIn}
+)
+
+// Filters maps the DB columns a collection can be filtered by to the
+// operators allowed on each column.
+type Filters map[string][]FilterOperator
+
+// FilterError describes a query parameter that couldn't be used as a
+// filter.
+type FilterError struct {
+	Param    string         `json:"param"`
+	Field    string         `json:"field"`
+	Operator FilterOperator `json:"operator,omitempty"`
+	Message  string         `json:"message"`
+}
+
+// FilterErrors is the error returned for a request with invalid
+// filters. Collection handlers respond with it in the `filters` key
+// of a 400 response.
+type FilterErrors []FilterError
+
+func (errs FilterErrors) Error() string {
+	msgs := []string{}
+	for _, err := range errs {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", err.Param, err.Message))
+	}
+	return "invalid filters: " + strings.Join(msgs, ", ")
+}
+
+var (
+	regexpFilterParam = regexp.MustCompile(`^(\w+)(?:\[(\w+)\])?$`)
+
+	likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+	filterSQL = map[FilterOperator]string{
+		FilterEq:       "%s = ?",
+		FilterNe:       "%s <> ?",
+		FilterLt:       "%s < ?",
+		FilterLte:      "%s <= ?",
+		FilterGt:       "%s > ?",
+		FilterGte:      "%s >= ?",
+		FilterContains: `%s LIKE ? ESCAPE '\'`,
+		FilterPrefix:   `%s LIKE ? ESCAPE '\'`,
+		FilterIn:       "%s IN (?)",
+	}
+)
+
+// reservedParams are query parameters used by Collection handlers
+// for something other than filtering.
+var reservedParams = map[string]bool{
+	"limit":   true,
+	"offset":  true,
+	"cursor":  true,
+	"sort":    true,
+	"fields":  true,
+	"include": true,
+	"deleted": true,
+}
+
+// filter is a single condition parsed from the query string.
+type filter struct {
+	column   string
+	operator FilterOperator
+	value    interface{}
+}
+
+// check panics if the filters refer to columns that aren't in the
+// table of `scope`, or to unknown operators.
+func (filters Filters) check(scope *gorm.Scope) {
+	for column, operators := range filters {
+		if !hasColumn(scope, column) {
+			panic(fmt.Sprintf("resources: can't filter on unknown column %q of %s", column, scope.TableName()))
+		}
+		for _, op := range operators {
+			if _, ok := filterSQL[op]; !ok && op != FilterNull {
+				panic(fmt.Sprintf("resources: unknown filter operator %q for column %q", op, column))
+			}
+		}
+	}
+}
+
+// parse finds the filters in `query`, ignoring reserved parameters.
+// Nothing is parsed when no filters are allowed. Bare parameters
+// that aren't filtered columns (eg. a cache-busting `_=123`) are
+// ignored, but invalid `column[operator]` parameters are rejected.
+func (filters Filters) parse(query url.Values) ([]filter, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+
+	params := []string{}
+	for param := range query {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+
+	result := []filter{}
+	errs := FilterErrors{}
+	for _, param := range params {
+		if reservedParams[param] {
+			continue
+		}
+
+		bracketed := strings.Contains(param, "[")
+		m := regexpFilterParam.FindStringSubmatch(param)
+		if m == nil {
+			if bracketed {
+				errs = append(errs, FilterError{Param: param, Message: "invalid filter"})
+			}
+			continue
+		}
+
+		column, op := m[1], FilterOperator(m[2])
+		if op == "" {
+			op = FilterEq
+		}
+
+		operators, ok := filters[column]
+		if !ok && !bracketed {
+			continue
+		}
+		if !ok {
+			errs = append(errs, FilterError{Param: param, Field: column, Message: "unknown field"})
+			continue
+		}
+		if !hasOperator(operators, op) {
+			errs = append(errs, FilterError{Param: param, Field: column, Operator: op, Message: "operator not allowed"})
+			continue
+		}
+
+		for _, v := range query[param] {
+			f := filter{column: column, operator: op, value: v}
+			switch op {
+			case FilterContains:
+				f.value = "%" + likeEscaper.Replace(v) + "%"
+			case FilterPrefix:
+				f.value = likeEscaper.Replace(v) + "%"
+			case FilterIn:
+				f.value = strings.Split(v, ",")
+			case FilterNull:
+				if v != "true" && v != "false" {
+					errs = append(errs, FilterError{Param: param, Field: column, Operator: op, Message: "value must be true or false"})
+					continue
+				}
+				f.value = v == "true"
+			}
+			result = append(result, f)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return result, nil
+}
+
+// filterScope returns a Gorm scope that adds `filters` to a query on
+// the table of `scope`.
+func filterScope(scope *gorm.Scope, filters []filter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, f := range filters {
+			column := fmt.Sprintf("%s.%s", scope.QuotedTableName(), scope.Quote(f.column))
+			if f.operator == FilterNull {
+				if f.value.(bool) {
+					db = db.Where(column + " IS NULL")
+				} else {
+					db = db.Where(column + " IS NOT NULL")
+				}
+				continue
+			}
+			db = db.Where(fmt.Sprintf(filterSQL[f.operator], column), f.value)
+		}
+		return db
+	}
+}
+
+func hasColumn(scope *gorm.Scope, column string) bool {
+	for _, field := range scope.Fields() {
+		if field.IsNormal && field.DBName == column {
+			return true
+		}
+	}
+	return false
+}
+
+func hasOperator(operators []FilterOperator, op FilterOperator) bool {
+	for _, o := range operators {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package resources_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/theplant/resources"
+)
+
+func TestCollectionFilters(t *testing.T) {
+	u, rs := createOwnedResources(t, 0)
+	for _, text := range []string{"foo", "foobar", "bar", "100%", "100 percent"} {
+		r := Resource{UserID: u.ID, Text: text}
+		assertNoErr(db.Save(&r).Error)
+		rs = append(rs, r)
+	}
+
+	collection := res.CollectionWith(resources.CollectionOptions{
+		DefaultLimit: 10,
+		Filters: resources.Filters{
+			"text": resources.FilterText,
+			"id":   resources.FilterComparable,
+		},
+	})
+	req := mountOwnerHandler(t, &u, collection)
+
+	tests := []struct {
+		Query url.Values
+		IDs   []uint
+	}{
+		{url.Values{"text": {"foo"}}, ids(rs[0:1])},
+		{url.Values{"text[eq]": {"foo"}}, ids(rs[0:1])},
+		{url.Values{"text[contains]": {"bar"}}, ids(rs[1:3])},
+		{url.Values{"text[prefix]": {"foo"}}, ids(rs[0:2])},
+		{url.Values{"text[contains]": {"%"}}, ids(rs[3:4])},
+		{url.Values{"text[in]": {"foo,bar"}}, []uint{rs[0].ID, rs[2].ID}},
+		{url.Values{"text[ne]": {"foo"}, "id[lte]": {strconv.Itoa(int(rs[2].ID))}}, ids(rs[1:3])},
+		{url.Values{"text[contains]": {"foo"}, "limit": {"1"}}, ids(rs[0:1])},
+	}
+
+	for _, test := range tests {
+		resp := req(test.Query.Encode())
+		if resp.Code != http.StatusOK {
+			t.Fatalf("Error filtering collection with %q\nexpected %d, got %d: %v", test.Query.Encode(), http.StatusOK, resp.Code, resp)
+		}
+
+		got := ids(unmarshalCollection(t, resp))
+		if !equalIDs(got, test.IDs) {
+			t.Fatalf("Wrong results filtering with %q\nexpected: '%v'\ngot:      '%v'", test.Query.Encode(), test.IDs, got)
+		}
+	}
+
+	resp := req("text[contains]=foo&limit=1")
+	if total := resp.Header().Get("X-Total-Count"); total != "2" {
+		t.Fatalf("Wrong total count for filtered collection\nexpected: '2'\ngot:      '%v'", total)
+	}
+	if next := linkHeader(resp)["next"]; next == nil || next.Query().Get("text[contains]") != "foo" {
+		t.Fatalf("Filter missing from next link, got: '%v'", next)
+	}
+}
+
+func TestCollectionInvalidFilters(t *testing.T) {
+	u, _ := createOwnedResources(t, 1)
+
+	collection := res.CollectionWith(resources.CollectionOptions{
+		DefaultLimit: 10,
+		Filters:      resources.Filters{"text": {resources.FilterEq}},
+	})
+	req := mountOwnerHandler(t, &u, collection)
+
+	tests := []struct {
+		Query    string
+		Expected resources.FilterError
+	}{
+		{"user_id[eq]=1", resources.FilterError{Param: "user_id[eq]", Field: "user_id", Message: "unknown field"}},
+		{"text[contains]=foo", resources.FilterError{Param: "text[contains]", Field: "text", Operator: "contains", Message: "operator not allowed"}},
+		{"text[eq][eq]=foo", resources.FilterError{Param: "text[eq][eq]", Message: "invalid filter"}},
+	}
+
+	for _, test := range tests {
+		resp := req(test.Query)
+		if resp.Code != http.StatusBadRequest {
+			t.Fatalf("Error filtering collection with %q\nexpected %d, got %d: %v", test.Query, http.StatusBadRequest, resp.Code, resp)
+		}
+
+		b, err := ioutil.ReadAll(resp.Body)
+		assertNoErr(err)
+
+		result := struct {
+			Error   string
+			Filters []resources.FilterError
+		}{}
+		assertNoErr(json.Unmarshal(b, &result))
+
+		if len(result.Filters) != 1 || result.Filters[0] != test.Expected {
+			t.Fatalf("Wrong error filtering with %q\nexpected: '%v'\ngot:      '%v'", test.Query, test.Expected, result.Filters)
+		}
+	}
+}
+
+func TestCollectionIgnoredParams(t *testing.T) {
+	u, _ := createOwnedResources(t, 1)
+
+	collection := res.CollectionWith(resources.CollectionOptions{
+		DefaultLimit: 10,
+		Filters:      resources.Filters{"text": {resources.FilterEq}},
+		Deleted:      true,
+	})
+	req := mountOwnerHandler(t, &u, collection)
+
+	for _, query := range []string{"_=123", "deleted=include", "user_id=1", "text=text&_=123"} {
+		if resp := req(query); resp.Code != http.StatusOK {
+			t.Fatalf("Error listing collection with %q\nexpected %d, got %d: %v", query, http.StatusOK, resp.Code, resp)
+		}
+	}
+}
+
+func TestCollectionFiltersUnknownColumn(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Didn't panic when filtering on an unknown column")
+		}
+	}()
+
+	res.CollectionWith(resources.CollectionOptions{
+		Filters: resources.Filters{"nope": {resources.FilterEq}},
+	})
+}
@@ -1,17 +1,24 @@
 // Package resources provides a default implementation, as
 // "specialised" `gin.HandlerFunc`s, of a RESTful (*eugh*) API for
 // Gorm-backed models.
+//
+// Models are identified in URLs by a single column (see IDCodec).
+// Composite keys aren't supported: models with them need a unique
+// column, eg. a slug or UUID, to be identified by.
 package resources
 
 import (
 	"errors"
-	"log"
+	"fmt"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
 	"reflect"
-	"regexp"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/jinzhu/gorm"
 )
 
@@ -25,17 +32,15 @@ var (
 	ErrRequestMissingAttrs = errors.New("couldn't bind resource")
 
 	// AcceptableError will be compared against the type of errors
-	// from `db.Create`. If the types match, Post will respond with
+	// returned to any handler (eg. from `db.Create`). If the types
+	// match, the handler will respond with
 	// HTTPStatusUnprocessableEntity instead of panicking.
 	//
-	// This could be a lot smarter, but it was the simplest thing that
-	// supported my use-case at the time, without introducing more
-	// package dependencies.
+	// Deprecated: use WithErrorMapper or WithAcceptableError to set
+	// this per resource.
 	AcceptableError reflect.Type
 )
 
-var regexpID = regexp.MustCompile(`\d+`)
-
 // DBModel defines an interface for ownership/authorisation when
 // finding and creating DB models.
 type DBModel interface {
@@ -75,167 +80,812 @@ type UserModelHandler func(*gin.Context, User, DBModel)
 // Resource is a collection of specialised gin.HandlerFunc functions
 // and handler wrappers for exporting Gorm-backed DB structs as HTTP
 // API resources/endpoints.
+//
+// If the resource was created with WithPolicy, every handler checks
+// the Policy before acting, and responds to a refusal with the
+// mapped response for the Policy's error (eg. 403 for ErrForbidden).
+//
+// The JSON responses of Collection, Post, Get, Patch and Put only
+// include the fields listed in the `fields` query parameter (eg.
+// `?fields=id,text`), or in the resource's view (see WithView), if
+// any. They respond with 400 if `fields` names an unknown field.
+//
+// Response bodies, including errors, are rendered by the serializer
+// for the request's `Accept` header (see WithSerializers), which is
+// plain JSON by default. Handlers respond with 406 if none of the
+// resource's serializers is acceptable.
+//
+// Post, Patch, Put and Delete call the resource's Hooks (see
+// WithHooks) and the model's hook methods (eg. BeforeCreateHook)
+// around their changes, responding to any error from a hook with its
+// mapped response. If the resource was created with WithTransactions,
+// they run in a transaction (see GetTx) that is rolled back if they
+// respond with an error.
 type Resource struct {
 	// Collection responds with:
 	//
-	// * 200 with JSON body of all resouces of this type owned by the
-	//   given user
+	// * 200 with JSON body of a page of the resources of this type
+	//   owned by the given user, a `Link` header with the `next`
+	//   and `prev` pages, and an `X-Total-Count` header
+	// * 304 if the `If-None-Match` header matches the page's `ETag`
+	//   header. The `Last-Modified` header (the latest `UpdatedAt`
+	//   time of the resources on the page) is informational:
+	//   `If-Modified-Since` is ignored, as deleting resources doesn't
+	//   change it
+	// * 400 if the pagination, filter, sort, deleted or include
+	//   parameters are invalid
+	// * the mapped response for errors handled by the resource's
+	//   ErrorMapper, or 500 for any other error
+	//
+	// Pages are selected with the `limit` and `offset` query
+	// parameters, or with `limit` and the opaque `cursor` found in
+	// the `Link` header. An empty `cursor` starts keyset pagination
+	// from the first resource. Cursors page by the primary key, so
+	// they can only be used if it's an integer.
+	//
+	// Requests can be filtered by the columns allowed in
+	// CollectionOptions.Filters, eg. `?text[contains]=foo`, and
+	// sorted by the columns in CollectionOptions.Sortable, eg.
+	// `?sort=-created_at,text`. Resources are always ordered by
+	// primary key after any requested sort.
+	//
+	// If CollectionOptions.Deleted is set, soft-deleted resources can
+	// be listed with `?deleted=include`, or listed alone with
+	// `?deleted=only`.
+	//
+	// Associations allowed by WithIncludes are preloaded when listed
+	// in the `include` parameter, eg. `?include=user`.
 	Collection ModelHandler
 
+	// CollectionWith builds a Collection handler with the given
+	// options. Collection is built with the options given to
+	// WithCollectionOptions, or DefaultCollectionOptions.
+	CollectionWith func(CollectionOptions) ModelHandler
+
 	// Post creates a single resource that will be owned by this user
 	// by:
 	//
-	// 1. Binding the request body to the struct returned by `single`
+	// 1. Binding the request body to the struct returned by `single`,
+	//    apart from fields that can't be written (see
+	//    WithUnwritableFields)
 	// 2. Setting the owner id of the struct to the collection owner.
 	// 3. Saving the struct in the database.
 	//
 	// Responds with:
-	// * 422 if binding failed
+	// * 422 if binding failed, or the body has fields that can't be
+	//   written, listing the invalid fields (see ValidationError)
 	// * 201 if saved to DB (setting `Location` header to result of
-	//   calling `linker`)
-	//
-	// Panics on database error.
+	//   calling the linker, if any)
+	// * the mapped response for errors handled by the resource's
+	//   ErrorMapper, or 500 for any other error
 	Post UserModelHandler
 
 	// Get responds with:
 	//
-	// * 200 with JSON body of serialised struct
+	// * 200 with JSON body of serialised struct, an `ETag` header of
+	//   its version (see WithVersionColumn) and representation, and
+	//   a `Last-Modified` header of its `UpdatedAt` time
+	// * 304 if the `If-None-Match` or `If-Modified-Since` header
+	//   matches the struct
+	//
+	// Both responses have the `Cache-Control` header given to
+	// WithCacheControl, if any.
 	Get ModelHandler
 
-	// Patch works similarly to Post, but updates given struct with
-	// request.
+	// Patch updates the given struct with the fields present in the
+	// request body, which must be a JSON object. Only the present
+	// fields are validated, and only their columns are updated, so
+	// fields can be set to zero values (`false`, `0`, `""`) and
+	// `required` fields can be left out.
 	//
-	// Responds with:
-	// * 422 if binding failed
-	// * 200 if DB updated
+	// The body can instead be a JSON Merge Patch (RFC 7396) or a JSON
+	// Patch (RFC 6902), given with a Content-Type of
+	// MediaTypeMergePatch or MediaTypeJSONPatch. These are applied to
+	// the JSON encoding of the struct, and any changed fields are
+	// then validated and updated as above. A JSON Patch `test`
+	// operation can be used to make the update conditional.
+	//
+	// If the request has an `If-Match` header, the update only
+	// happens if the resource still has one of the given entity
+	// tags (of any representation). The check is part of the UPDATE statement, so concurrent
+	// updates can't both succeed.
 	//
-	// Panics on database error.
+	// Responds with:
+	// * 422 if binding failed, or the body has fields that aren't DB
+	//   columns of the struct or can't be updated (see
+	//   WithUnwritableFields), listing the invalid fields (see
+	//   ValidationError)
+	// * 422 if a JSON Patch can't be applied (see PatchError)
+	// * 409 if a JSON Patch `test` operation failed
+	// * 412 if the `If-Match` header doesn't match the resource
+	// * 428 if the `If-Match` header is missing, and the resource was
+	//   created with WithRequirePreconditions
+	// * 200 if DB updated, with the new `ETag`
+	// * the mapped response for errors handled by the resource's
+	//   ErrorMapper, or 500 for any other error
 	Patch ModelHandler
 
+	// Put replaces the resource identified by the `:id` param with
+	// the request body, which must be a complete representation of
+	// the resource. It:
+	//
+	// 1. Binds the request body to the struct returned by `single`,
+	//    ignoring fields that can't be written (eg. the ID, Gorm
+	//    timestamps and associations) if they're unchanged, so that
+	//    the representation given by Get can be PUT back
+	// 2. Sets the owner and parent of the struct to the given user
+	//    and parent, and its ID to the `:id` param, so that the body
+	//    can't move a resource.
+	// 3. Saves every field of the struct that can be updated (see
+	//    WithUnwritableFields), so fields left out of the body are
+	//    zeroed.
+	//
+	// If no resource has the given ID, and the resource was created
+	// with WithCreateOnPut, the struct is created with that ID
+	// instead.
+	//
+	// `If-Match` headers are handled as for Patch.
+	//
+	// Responds with:
+	// * 422 if binding failed, or the body changes fields that can't
+	//   be written, listing the invalid fields (see ValidationError)
+	// * 404 if the resource doesn't exist (and can't be created, eg.
+	//   because a soft-deleted resource has its ID), or has a
+	//   different owner or parent to the given user and parent
+	// * 412 if the `If-Match` header doesn't match the resource
+	// * 428 if the `If-Match` header is missing, and the resource was
+	//   created with WithRequirePreconditions
+	// * 200 if DB updated, with the new `ETag`
+	// * 201 if created (setting `Location` header to result of
+	//   calling the linker, if any)
+	// * the mapped response for errors handled by the resource's
+	//   ErrorMapper, or 500 for any other error
+	Put UserModelHandler
+
+	// PutForKey builds a Put handler that finds the resource to
+	// replace via the given `key` parameter.
+	PutForKey func(string) UserModelHandler
+
 	// Delete deletes the struct from the database (supporting soft-delete)
 	//
+	// `If-Match` headers are handled as for Patch.
+	//
 	// Responds with:
 	// * 204
-	//
-	// Panics on database error.
+	// * 412 if the `If-Match` header doesn't match the resource
+	// * 428 if the `If-Match` header is missing, and the resource was
+	//   created with WithRequirePreconditions
+	// * the mapped response for errors handled by the resource's
+	//   ErrorMapper, or 500 for any other error
 	Delete ModelHandler
 
+	// BulkPost creates a resource owned by the given user, with the
+	// given parent, for each object in the JSON array of the request
+	// body, as Post does. Every item is created in a single
+	// transaction.
+	//
+	// In the default BulkAllOrNothing mode (see WithBulkMode), it
+	// responds with:
+	// * 201 with a JSON array of the created resources
+	// * the response for the error of the first item to fail (see
+	//   BulkItemError), having created none of them
+	//
+	// In BulkPerItem mode, the items that succeed are created, and it
+	// responds with 207 and a JSON array of the result of each item
+	// (see BulkResult).
+	//
+	// All bulk handlers respond with 422 if the body isn't a JSON
+	// array.
+	BulkPost UserModelHandler
+
+	// BulkPatch updates resources with the fields in each object of
+	// the JSON array of the request body, as Patch does. The resource
+	// to update is given by the primary key field of the object (eg.
+	// `{"ID": 1, "Text": "text"}`), and must be owned by the given
+	// user and have the given parent, or the item fails with 404.
+	//
+	// It responds as BulkPost, with 200 and the updated resources in
+	// BulkAllOrNothing mode.
+	BulkPatch UserModelHandler
+
+	// BulkDelete deletes the resources with the IDs in the JSON array
+	// of the request body (eg. `[1, 2]`), which must be owned by the
+	// given user and have the given parent, or the item fails with
+	// 404.
+	//
+	// It responds as BulkPost, with 204 in BulkAllOrNothing mode.
+	BulkDelete UserModelHandler
+
+	// Restore undoes the soft-delete of the struct, which should be
+	// provided by ProvideUnscopedModel. `If-Match` headers are
+	// handled as for Patch, and the update hooks are called.
+	//
+	// Responds with:
+	// * 200 with the restored struct, and its new `ETag`
+	// * 412 if the `If-Match` header doesn't match the resource
+	// * 428 if the `If-Match` header is missing, and the resource was
+	//   created with WithRequirePreconditions
+	// * the mapped response for errors handled by the resource's
+	//   ErrorMapper, or 500 for any other error
+	Restore ModelHandler
+
+	// Purge deletes the struct from the database permanently, even if
+	// it supports soft-delete. It is otherwise the same as Delete,
+	// and can be given a soft-deleted struct by ProvideUnscopedModel.
+	Purge ModelHandler
+
 	// ProvideModelForKey provides a ProvideModel that looked up DB
-	// model via the given `key` parameter.
+	// model via the given `key` parameter, parsed by the resource's
+	// IDCodec (see WithIDCodec). Responds with 404 if the parameter
+	// isn't a valid ID.
 	ProvideModelForKey func(string) func(ModelHandler) gin.HandlerFunc
 
 	// ProvideModel wraps a resource handler to provide the requested
 	// DB model as a parameter to the function. DB model is looked up
-	// via an `:id` param. It performs no authorisation.
+	// via an `:id` param. It performs no authorisation (see
+	// RequireOwner).
+	//
+	// Associations allowed by WithIncludes are preloaded when listed
+	// in the `include` parameter, eg. `?include=comments.author`.
 	//
 	// Responds with:
+	// * 400 if the `include` parameter is invalid
 	// * 404 if DB model with given ID cannot be found
 	// * Result of wrapped handler otherwise
-	//
-	// Panics on database error.
+	// * the mapped response for errors handled by the resource's
+	//   ErrorMapper, or 500 for any other error
 	ProvideModel func(ModelHandler) gin.HandlerFunc
+
+	// ProvideUnscopedModelForKey provides a ProvideUnscopedModel that
+	// looks up the DB model via the given `key` parameter.
+	ProvideUnscopedModelForKey func(string) func(ModelHandler) gin.HandlerFunc
+
+	// ProvideUnscopedModel is the same as ProvideModel, but also finds
+	// soft-deleted DB models, for Restore and Purge.
+	ProvideUnscopedModel func(ModelHandler) gin.HandlerFunc
 }
 
 // New creates a new resource that exposes the DBModel returned by
 // `single` as a HTTP API. `collection` should return an array of the
 // same type as `single`.
+//
+// New is equivalent to calling NewWithOptions with WithCollection
+// and WithLinker.
 func New(db *gorm.DB, single func() DBModel, collection func() interface{}, linker func(id uint) string) Resource {
+	return NewWithOptions(db, single, WithCollection(collection), WithLinker(linker))
+}
+
+// NewWithOptions creates a new resource that exposes the DBModel
+// returned by `single` as a HTTP API, configured by `opts`.
+func NewWithOptions(db *gorm.DB, single func() DBModel, opts ...Option) Resource {
+	h := &handlers{
+		db:      db,
+		single:  single,
+		options: newOptions(single, opts),
+	}
 
 	r := Resource{}
 
-	r.Collection = func(ctx *gin.Context, owner DBModel) {
-		c := collection()
-		if err := db.Model(owner).Related(c).Error; err != nil && err != gorm.ErrRecordNotFound {
-			panic(err)
+	r.CollectionWith = h.collectionWith
+	r.Collection = r.CollectionWith(h.collectionOptions)
+	r.Post = func(ctx *gin.Context, user User, parent DBModel) {
+		h.transaction(ctx, func(h *handlers) { h.post(ctx, user, parent) })
+	}
+	r.Get = h.get
+	r.Patch = func(ctx *gin.Context, s DBModel) {
+		h.transaction(ctx, func(h *handlers) { h.patch(ctx, s) })
+	}
+	r.PutForKey = func(key string) UserModelHandler {
+		return func(ctx *gin.Context, user User, parent DBModel) {
+			h.transaction(ctx, func(h *handlers) { h.putForKey(key)(ctx, user, parent) })
+		}
+	}
+	r.Put = r.PutForKey("id")
+	r.Delete = func(ctx *gin.Context, s DBModel) {
+		h.transaction(ctx, func(h *handlers) { h.delete(ctx, s) })
+	}
+	r.BulkPost = h.bulkPost
+	r.BulkPatch = h.bulkPatch
+	r.BulkDelete = h.bulkDelete
+	r.Restore = func(ctx *gin.Context, s DBModel) {
+		h.transaction(ctx, func(h *handlers) { h.restore(ctx, s) })
+	}
+	r.Purge = func(ctx *gin.Context, s DBModel) {
+		h.transaction(ctx, func(h *handlers) { h.unscoped().delete(ctx, s) })
+	}
+	r.ProvideModelForKey = h.provideModelForKey
+	r.ProvideModel = r.ProvideModelForKey("id")
+	r.ProvideUnscopedModelForKey = h.unscoped().provideModelForKey
+	r.ProvideUnscopedModel = r.ProvideUnscopedModelForKey("id")
+
+	return r
+}
+
+// handlers implements the handlers of a Resource.
+type handlers struct {
+	*options
+
+	db     *gorm.DB
+	single func() DBModel
+}
+
+func (h *handlers) collectionWith(opts CollectionOptions) ModelHandler {
+	db := h.db
+
+	scope := db.NewScope(h.single())
+	pk := fmt.Sprintf("%s.%s", scope.QuotedTableName(), scope.Quote(scope.PrimaryKey()))
+	cursors := integerKey(scope)
+	opts.Filters.check(scope)
+	checkSortable(scope, opts.Sortable)
+	checkDeleted(scope, opts)
+
+	return func(ctx *gin.Context, owner DBModel) {
+		query := ctx.Request.URL.Query()
+
+		serializer, err := h.serializer(ctx)
+		if err != nil {
+			h.abortWithError(ctx, err)
+			return
 		}
 
-		ctx.JSON(http.StatusOK, c)
+		p, err := parsePage(query, opts)
+		if err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
+		if p.cursor != nil && !cursors {
+			h.abortWithError(ctx, ErrCursorUnsupported)
+			return
+		}
+
+		deleted, err := parseDeleted(query, scope, opts)
+		if err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
+
+		filters, err := opts.Filters.parse(query)
+		if err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
+
+		fields, err := h.outputFields(ctx)
+		if err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
+
+		includes, err := h.parseIncludes(ctx)
+		if err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
+		filtered := db.Model(owner).Scopes(deleted, filterScope(scope, filters))
+		if h.policy != nil {
+			listScope, err := h.policy.CanList(GetUser(ctx), owner)
+			if err != nil {
+				h.abortWithError(ctx, err)
+				return
+			}
+			filtered = filtered.Scopes(scopes(listScope)...)
+		}
+
+		total, err := countRelated(filtered, newCollection(h.collection))
+		if err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
+
+		c := newCollection(h.collection)
+		if err := p.apply(filtered, scope, pk).Scopes(h.selectColumns(scope, fields), includeScope(includes)).Related(c).Error; err != nil && err != gorm.ErrRecordNotFound {
+			h.abortWithError(ctx, err)
+			return
+		}
+
+		items, links := p.fetched(reflect.ValueOf(c).Elem(), total)
+		if items.IsNil() {
+			items = reflect.MakeSlice(items.Type(), 0, 0)
+		}
+
+		pages, err := links.list(ctx.Request)
+		if err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
+
+		docs := make([]Document, items.Len())
+		for i := range docs {
+			item := items.Index(i)
+			if item.Kind() != reflect.Ptr {
+				item = item.Addr()
+			}
+			if docs[i], err = h.document(ctx, item.Interface(), fields); err != nil {
+				h.abortWithError(ctx, err)
+				return
+			}
+		}
+
+		body, err := serializer.Collection(docs, pages)
+		if err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
+
+		ctx.Header("X-Total-Count", strconv.Itoa(total))
+		if len(pages) > 0 {
+			ctx.Header("Link", formatLinks(pages))
+		}
+		// Only the ETag changes when models are deleted
+		if !h.respondCached(ctx, collectionETag(body, total), h.collectionLastModified(items), false) {
+			return
+		}
+		ctx.Data(http.StatusOK, serializer.ContentType(), body)
 	}
+}
 
-	r.Post = func(ctx *gin.Context, user User, parent DBModel) {
-		s := single()
-		if ctx.BindJSON(s) != nil {
-			ctx.JSON(HTTPStatusUnprocessableEntity, errToJSON(ErrRequestMissingAttrs))
+func (h *handlers) post(ctx *gin.Context, user User, parent DBModel) {
+	SetUser(ctx, user)
+
+	if err := h.checkOutput(ctx); err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	s := h.single()
+	if err := h.writableRequest(ctx, s, ActionCreate, nil); err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+	if err := binding.JSON.Bind(ctx.Request, s); err != nil {
+		h.abortWithError(ctx, newValidationError(err, s))
+		return
+	}
+
+	location, err := h.create(ctx, s, user, parent)
+	if err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	if location != "" {
+		ctx.Header("Location", location)
+	}
+	h.respond(ctx, http.StatusCreated, s)
+}
+
+// create saves `s` as a new DB model owned by `user`, with parent
+// `parent`, returning the location of the new model (if the resource
+// has a linker). Nothing is written to the response, so that the
+// caller can respond with either the error or the created model.
+func (h *handlers) create(ctx *gin.Context, s DBModel, user User, parent DBModel) (string, error) {
+	if err := s.SetOwner(user); err != nil {
+		return "", err
+	}
+	if err := s.SetParent(parent); err != nil {
+		return "", err
+	}
+
+	if h.policy != nil {
+		if err := h.policy.CanCreate(user, s, parent); err != nil {
+			return "", err
+		}
+	}
+
+	if err := h.beforeCreate(ctx, s, user, parent); err != nil {
+		return "", err
+	}
+	if err := h.db.Create(s).Error; err != nil {
+		return "", err
+	}
+	if err := h.afterCreate(ctx, s, user, parent); err != nil {
+		return "", err
+	}
+
+	return h.link(ctx, s)
+}
+
+func (h *handlers) get(ctx *gin.Context, s DBModel) {
+	if h.policy != nil {
+		if err := h.policy.CanRead(GetUser(ctx), s, GetParent(ctx)); err != nil {
+			h.abortWithError(ctx, err)
 			return
 		}
+	}
+
+	contentType, body, err := h.render(ctx, s)
+	if err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	if !h.respondCached(ctx, h.representationETag(s, contentType, body), h.lastModified(s), true) {
+		return
+	}
+	ctx.Data(http.StatusOK, contentType, body)
+}
+
+func (h *handlers) patch(ctx *gin.Context, s DBModel) {
+	if h.policy != nil {
+		if err := h.policy.CanUpdate(GetUser(ctx), s, GetParent(ctx)); err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
+	}
+
+	ifMatch, err := h.ifMatch(ctx)
+	if err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	if err := h.checkOutput(ctx); err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	body, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(ctx.Request.Header.Get("Content-Type"))
+	if body, err = patchObject(mediaType, body, s); err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	if body, err = h.writable(ctx, s, ActionUpdate, body); err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	updates, err := bindPatch(h.db, body, s)
+	if err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	if err := h.update(ctx, s, ifMatch, updates); err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	h.respondTagged(ctx, http.StatusOK, s)
+}
+
+func (h *handlers) putForKey(key string) UserModelHandler {
+	return func(ctx *gin.Context, user User, parent DBModel) {
+		id := ctx.Param(key)
+		where, err := h.whereID(id)
+		if err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
+
+		SetUser(ctx, user)
+
+		ifMatch, err := h.ifMatch(ctx)
+		if err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
+
+		if err := h.checkOutput(ctx); err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
+
+		existing := h.single()
+		err = h.db.Scopes(where).First(existing).Error
+		creating := err == gorm.ErrRecordNotFound && h.createOnPut
+		if creating {
+			// A soft-deleted resource keeps its ID until it's purged,
+			// so it can't be created again (but can be restored)
+			err = h.db.Unscoped().Scopes(where).First(h.single()).Error
+			if err == nil {
+				err = gorm.ErrRecordNotFound
+				creating = false
+			} else if err == gorm.ErrRecordNotFound {
+				err = nil
+			}
+		}
+		if err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
+
+		action, stored := ActionUpdate, existing
+		if creating {
+			action, stored = ActionCreate, nil
+		}
+
+		s := h.single()
+		if err := h.writableRequest(ctx, s, action, stored); err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
+		if err := binding.JSON.Bind(ctx.Request, s); err != nil {
+			h.abortWithError(ctx, newValidationError(err, s))
+			return
+		}
+
+		if creating {
+			// There is no current entity to match
+			if ifMatch != nil {
+				h.abortWithError(ctx, ErrPreconditionFailed)
+				return
+			}
+
+			if err := h.setID(s, id); err != nil {
+				h.abortWithError(ctx, gorm.ErrRecordNotFound)
+				return
+			}
+
+			location, err := h.create(ctx, s, user, parent)
+			if err != nil {
+				h.abortWithError(ctx, err)
+				return
+			}
+
+			if location != "" {
+				ctx.Header("Location", location)
+			}
+			h.respond(ctx, http.StatusCreated, s)
+			return
+		}
+
 		if err := s.SetOwner(user); err != nil {
-			panic(err)
+			h.abortWithError(ctx, err)
+			return
 		}
 		if err := s.SetParent(parent); err != nil {
-			panic(err)
+			h.abortWithError(ctx, err)
+			return
+		}
+
+		// Resources can't be moved to another owner or parent
+		if existing.OwnerID() != s.OwnerID() || existing.ParentID() != s.ParentID() {
+			h.abortWithError(ctx, gorm.ErrRecordNotFound)
+			return
 		}
 
-		if err := db.Create(s).Error; err != nil {
-			if reflect.TypeOf(err) == AcceptableError {
-				ctx.JSON(HTTPStatusUnprocessableEntity, errToJSON(err))
-			} else {
-				panic(err)
+		if h.policy != nil {
+			if err := h.policy.CanUpdate(user, existing, parent); err != nil {
+				h.abortWithError(ctx, err)
+				return
 			}
 		}
 
-		ctx.Header("Location", absURL(ctx.Request, linker(s.GetID())))
-		ctx.JSON(http.StatusCreated, s)
-	}
+		columns := h.writableColumns(ctx, s, replacement(h.db, s))
+		if err := h.update(ctx, existing, ifMatch, columns); err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
 
-	r.Get = func(ctx *gin.Context, s DBModel) {
-		ctx.JSON(http.StatusOK, s)
+		h.respondTagged(ctx, http.StatusOK, existing)
 	}
+}
 
-	r.Patch = func(ctx *gin.Context, s DBModel) {
-		newS := single()
-		if ctx.BindJSON(newS) != nil {
-			ctx.JSON(HTTPStatusUnprocessableEntity, errToJSON(ErrRequestMissingAttrs))
+func (h *handlers) delete(ctx *gin.Context, s DBModel) {
+	if h.policy != nil {
+		if err := h.policy.CanDelete(GetUser(ctx), s, GetParent(ctx)); err != nil {
+			h.abortWithError(ctx, err)
 			return
 		}
+	}
 
-		if err := db.Model(s).Updates(newS).Error; err != nil {
-			panic(err)
-		}
+	ifMatch, err := h.ifMatch(ctx)
+	if err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
 
-		ctx.JSON(http.StatusOK, s)
+	if err := h.beforeDelete(ctx, s); err != nil {
+		h.abortWithError(ctx, err)
+		return
 	}
 
-	r.Delete = func(ctx *gin.Context, s DBModel) {
-		if err := db.Delete(s).Error; err != nil {
-			panic(err)
+	result := h.db.Scopes(scopes(ifMatch)...).Delete(s)
+	if result.Error != nil {
+		h.abortWithError(ctx, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		if err := h.checkMatch(s, ifMatch); err != nil {
+			h.abortWithError(ctx, err)
+			return
 		}
+	}
 
-		ctx.AbortWithStatus(http.StatusNoContent)
+	if err := h.afterDelete(ctx, s); err != nil {
+		h.abortWithError(ctx, err)
+		return
 	}
 
-	r.ProvideModelForKey = func(key string) func(ModelHandler) gin.HandlerFunc {
-		return func(handler ModelHandler) gin.HandlerFunc {
-			return func(ctx *gin.Context) {
-				id := ctx.Param(key)
+	ctx.AbortWithStatus(http.StatusNoContent)
+}
 
-				if !regexpID.MatchString(id) {
-					ctx.AbortWithError(http.StatusNotFound, gorm.ErrRecordNotFound)
-					return
-				}
+func (h *handlers) provideModelForKey(key string) func(ModelHandler) gin.HandlerFunc {
+	return func(handler ModelHandler) gin.HandlerFunc {
+		return func(ctx *gin.Context) {
+			where, err := h.whereID(ctx.Param(key))
+			if err != nil {
+				h.abortWithError(ctx, err)
+				return
+			}
 
-				s := single()
-				if err := db.Where("id = ?", id).First(s).Error; err == gorm.ErrRecordNotFound {
-					ctx.AbortWithError(http.StatusNotFound, gorm.ErrRecordNotFound)
-					return
-				} else if err != nil {
-					panic(err)
-				}
+			includes, err := h.parseIncludes(ctx)
+			if err != nil {
+				h.abortWithError(ctx, err)
+				return
+			}
 
-				handler(ctx, s)
+			s := h.single()
+			if err := h.db.Scopes(includeScope(includes), where).First(s).Error; err != nil {
+				h.abortWithError(ctx, err)
+				return
 			}
+
+			ctx.Set(handlersKey, h)
+			handler(ctx, s)
 		}
 	}
+}
 
-	r.ProvideModel = r.ProvideModelForKey("id")
+// abortWithError responds to `err` with the status and body given by
+// errorResponse, rendered by the request's serializer (or the first
+// serializer, if none is acceptable).
+func (h *handlers) abortWithError(ctx *gin.Context, err error) {
+	status, body := h.errorResponse(ctx, err)
 
-	return r
+	serializer, err := h.serializer(ctx)
+	if err != nil {
+		serializer = h.serializers[0]
+	}
+
+	b, err := serializer.Error(status, body)
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctx.Data(status, serializer.ContentType(), b)
+	ctx.Abort()
+}
+
+// errorResponse returns the status and body to respond to `err`
+// with, as mapped by the resource's ErrorMapper, or a 500 if the
+// error isn't mapped, and passes `err` to the resource's ErrorSink.
+func (h *handlers) errorResponse(ctx *gin.Context, err error) (int, interface{}) {
+	status, body, ok := h.mapError(err)
+	if !ok {
+		status, body = http.StatusInternalServerError, errToJSON(ErrInternal)
+	}
+
+	if h.errorSink != nil {
+		h.errorSink(ctx, err)
+	}
+
+	// Add the request ID to the standard error envelope
+	if b, isEnvelope := body.(gin.H); isEnvelope && h.requestID != nil {
+		if id := h.requestID(ctx); id != "" {
+			withID := gin.H{"request_id": id}
+			for k, v := range b {
+				withID[k] = v
+			}
+			body = withID
+		}
+	}
+
+	return status, body
 }
 
 func errToJSON(err error) gin.H {
 	return gin.H{"error": err.Error()}
 }
 
-func absURL(req *http.Request, path string) string {
+func absURL(req *http.Request, path string) (string, error) {
 	server := url.URL{
 		Host: req.Host,
 	}
@@ -244,8 +894,8 @@ func absURL(req *http.Request, path string) string {
 
 	u, err := url.Parse(path)
 	if err != nil {
-		log.Panic(err)
+		return "", err
 	}
 
-	return base.ResolveReference(u).String()
+	return base.ResolveReference(u).String(), nil
 }
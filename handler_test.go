@@ -29,6 +29,7 @@ type Resource struct {
 	UserID uint
 	User   User
 	Text   string `binding:"required"`
+	Count  int    `binding:"max=10"`
 }
 
 var resourceError error
@@ -176,7 +177,10 @@ func TestPost(t *testing.T) {
 		t.Fatalf("Response with user relationship:\nexpected: '%v'\ngot:      '%v'", r.User.ID, resRes.User.ID)
 	}
 
-	// FIXME test location header
+	expectedLocation := fmt.Sprintf("/r/%d", resRes.ID)
+	if location := res.Header().Get("Location"); location != expectedLocation {
+		t.Fatalf("Wrong Location header:\nexpected: '%v'\ngot:      '%v'", expectedLocation, location)
+	}
 
 	res = req(postBody(t, struct{}{}))
 	expected = resources.HTTPStatusUnprocessableEntity
@@ -198,14 +202,17 @@ func TestPostWithError(t *testing.T) {
 	resourceError = errors.New("an error")
 	defer clearResourceErrors()
 
-	defer func() {
-		r := recover()
-		if r == nil {
-			t.Fatalf("Error POSTing resource\ndidn't panic on error when saving resource")
-		}
-	}()
+	res := req(postBody(t, body))
 
-	req(postBody(t, body))
+	expected := http.StatusInternalServerError
+	if res.Code != expected {
+		t.Fatalf("Error POSTing resource\nexpected %d, got %d: %v", expected, res.Code, res)
+	}
+
+	expectedBody := `{"error":"internal server error"}`
+	if result := strings.TrimSpace(res.Body.String()); result != expectedBody {
+		t.Fatalf("Response differs:\nexpected: '%v'\ngot:      '%v'", expectedBody, result)
+	}
 }
 
 func TestPostWithAcceptableError(t *testing.T) {
@@ -229,6 +236,15 @@ func TestPostWithAcceptableError(t *testing.T) {
 	if res.Code != expected {
 		t.Fatalf("Error POSTing resource\nexpected %d, got %d: %v", expected, res.Code, res)
 	}
+
+	expectedBody := `{"error":"acceptable error"}`
+	if result := strings.TrimSpace(res.Body.String()); result != expectedBody {
+		t.Fatalf("Response differs:\nexpected: '%v'\ngot:      '%v'", expectedBody, result)
+	}
+
+	if location := res.Header().Get("Location"); location != "" {
+		t.Fatalf("Unexpected Location header after error: '%v'", location)
+	}
 }
 
 func TestGet(t *testing.T) {
@@ -252,7 +268,7 @@ func TestGet(t *testing.T) {
 }
 
 func TestPatch(t *testing.T) {
-	r := Resource{}
+	r := Resource{Text: "original", Count: 5}
 	assertNoErr(db.Save(&r).Error)
 
 	req := mountResourceHandler(t, &r, res.Patch)
@@ -275,12 +291,65 @@ func TestPatch(t *testing.T) {
 		t.Fatalf("Didn't update resource\nexpected: '%v'\ngot:      '%v'", update.Text, reloaded.Text)
 	}
 
+	if reloaded.Count != 5 {
+		t.Fatalf("Updated field missing from request\nexpected: '%v'\ngot:      '%v'", 5, reloaded.Count)
+	}
+
+	// Zero values are updated, and required fields can be left out
+	res = req(postBody(t, map[string]interface{}{"Count": 0}))
+	if res.Code != expected {
+		t.Fatalf("Error PATCHting resource with zero value\nexpected %d, got %d: %v", expected, res.Code, res)
+	}
+
+	reloaded = &Resource{}
+	assertNoErr(db.Where("id = ?", r.ID).Find(&reloaded).Error)
+
+	if reloaded.Count != 0 || reloaded.Text != update.Text {
+		t.Fatalf("Didn't update resource with zero value\nexpected: '%v'\ngot:      '%v'", Resource{Text: update.Text}, reloaded)
+	}
+
 	res = req(postBody(t, struct{}{}))
-	expected = resources.HTTPStatusUnprocessableEntity
 	if res.Code != expected {
-		t.Fatalf("Error PATCHting resource with not enough data\nexpected %d, got %d: %v", expected, res.Code, res)
+		t.Fatalf("Error PATCHting resource with no fields\nexpected %d, got %d: %v", expected, res.Code, res)
+	}
+
+	// Keys match fields case-insensitively, as in encoding/json
+	res = req(postBody(t, map[string]interface{}{"count": 1}))
+	if res.Code != expected {
+		t.Fatalf("Error PATCHting resource with lower case key\nexpected %d, got %d: %v", expected, res.Code, res)
+	}
+
+	reloaded = &Resource{}
+	assertNoErr(db.Where("id = ?", r.ID).Find(&reloaded).Error)
+
+	if reloaded.Count != 1 {
+		t.Fatalf("Didn't update resource with lower case key\nexpected: '%v'\ngot:      '%v'", 1, reloaded.Count)
+	}
+
+	res = req(postBody(t, map[string]interface{}{"Count": 0}))
+	if res.Code != expected {
+		t.Fatalf("Error PATCHting resource with zero value\nexpected %d, got %d: %v", expected, res.Code, res)
+	}
+
+	for _, invalid := range []interface{}{
+		map[string]interface{}{"Text": ""},
+		map[string]interface{}{"Count": 11},
+		map[string]interface{}{"Unknown": 1},
+		[]string{"Text"},
+	} {
+		res = req(postBody(t, invalid))
+		expected = resources.HTTPStatusUnprocessableEntity
+		if res.Code != expected {
+			t.Fatalf("Error PATCHting resource with invalid data %v\nexpected %d, got %d: %v", invalid, expected, res.Code, res)
+		}
 	}
 
+	reloaded = &Resource{}
+	assertNoErr(db.Where("id = ?", r.ID).Find(&reloaded).Error)
+
+	if reloaded.Count != 0 || reloaded.Text != update.Text {
+		t.Fatalf("Updated resource with invalid data\nexpected: '%v'\ngot:      '%v'", Resource{Text: update.Text}, reloaded)
+	}
 }
 
 func TestDelete(t *testing.T) {
@@ -0,0 +1,187 @@
+package resources
+
+import (
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Hooks are functions that a resource's handlers call around the
+// changes they make (see WithHooks), eg. to write audit logs or to
+// enforce rules that need the request. Any of them can be nil.
+//
+// Before hooks can change the model before it's saved, and stop the
+// change by returning an error. After hooks are called once the change
+// has been made, and their errors are responded to without undoing
+// the change, unless the resource was created with WithTransactions.
+//
+// Errors from hooks are responded to through the resource's
+// ErrorMapper.
+type Hooks struct {
+	// BeforeCreate is called with the model to be created by Post
+	// (or Put), after its owner and parent are set.
+	BeforeCreate func(ctx *gin.Context, s DBModel, user User, parent DBModel) error
+
+	// AfterCreate is called with the created model.
+	AfterCreate func(ctx *gin.Context, s DBModel, user User, parent DBModel) error
+
+	// BeforeUpdate is called by Patch and Put with the model as it
+	// is, and as it will be after the update.
+	BeforeUpdate func(ctx *gin.Context, old DBModel, updated DBModel) error
+
+	// AfterUpdate is called with the model as it was, and as it is
+	// after the update.
+	AfterUpdate func(ctx *gin.Context, old DBModel, updated DBModel) error
+
+	// BeforeDelete is called with the model to be deleted.
+	BeforeDelete func(ctx *gin.Context, s DBModel) error
+
+	// AfterDelete is called with the deleted model.
+	AfterDelete func(ctx *gin.Context, s DBModel) error
+}
+
+// Models can implement the hook interfaces below to be called at the
+// same points as the resource's Hooks, after the resource's before
+// hooks and before its after hooks. The methods don't share the names
+// of Gorm's callbacks (eg. `BeforeCreate`), which Gorm would call with
+// different arguments.
+
+// BeforeCreateHook is implemented by models that are called before
+// they are created.
+type BeforeCreateHook interface {
+	BeforeCreateResource(ctx *gin.Context, user User, parent DBModel) error
+}
+
+// AfterCreateHook is implemented by models that are called after they
+// are created.
+type AfterCreateHook interface {
+	AfterCreateResource(ctx *gin.Context, user User, parent DBModel) error
+}
+
+// BeforeUpdateHook is implemented by models that are called before
+// they are updated. The method is called on the model as it will be
+// after the update, with the model as it is.
+type BeforeUpdateHook interface {
+	BeforeUpdateResource(ctx *gin.Context, old DBModel) error
+}
+
+// AfterUpdateHook is implemented by models that are called after they
+// are updated. The method is called on the updated model, with the
+// model as it was.
+type AfterUpdateHook interface {
+	AfterUpdateResource(ctx *gin.Context, old DBModel) error
+}
+
+// BeforeDeleteHook is implemented by models that are called before
+// they are deleted.
+type BeforeDeleteHook interface {
+	BeforeDeleteResource(ctx *gin.Context) error
+}
+
+// AfterDeleteHook is implemented by models that are called after they
+// are deleted.
+type AfterDeleteHook interface {
+	AfterDeleteResource(ctx *gin.Context) error
+}
+
+func (h *handlers) beforeCreate(ctx *gin.Context, s DBModel, user User, parent DBModel) error {
+	if h.hooks.BeforeCreate != nil {
+		if err := h.hooks.BeforeCreate(ctx, s, user, parent); err != nil {
+			return err
+		}
+	}
+	if hook, ok := s.(BeforeCreateHook); ok {
+		return hook.BeforeCreateResource(ctx, user, parent)
+	}
+	return nil
+}
+
+func (h *handlers) afterCreate(ctx *gin.Context, s DBModel, user User, parent DBModel) error {
+	if hook, ok := s.(AfterCreateHook); ok {
+		if err := hook.AfterCreateResource(ctx, user, parent); err != nil {
+			return err
+		}
+	}
+	if h.hooks.AfterCreate != nil {
+		return h.hooks.AfterCreate(ctx, s, user, parent)
+	}
+	return nil
+}
+
+// beforeUpdate calls the before update hooks with the model `s` and a
+// copy of it with `updates` (keyed by DB column) applied, and returns
+// the updates with any further changes the hooks made to the copy.
+func (h *handlers) beforeUpdate(ctx *gin.Context, s DBModel, updates map[string]interface{}) (map[string]interface{}, error) {
+	if h.hooks.BeforeUpdate == nil {
+		if _, ok := s.(BeforeUpdateHook); !ok {
+			return updates, nil
+		}
+	}
+
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+
+	updated := copyModel(s)
+	scope := h.db.NewScope(updated)
+	for column, value := range updates {
+		if err := scope.SetColumn(column, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if h.hooks.BeforeUpdate != nil {
+		if err := h.hooks.BeforeUpdate(ctx, s, updated); err != nil {
+			return nil, err
+		}
+	}
+	if hook, ok := updated.(BeforeUpdateHook); ok {
+		if err := hook.BeforeUpdateResource(ctx, s); err != nil {
+			return nil, err
+		}
+	}
+
+	original := replacement(h.db, s)
+	for column, value := range replacement(h.db, updated) {
+		if _, ok := updates[column]; ok || !reflect.DeepEqual(value, original[column]) {
+			updates[column] = value
+		}
+	}
+	return updates, nil
+}
+
+func (h *handlers) afterUpdate(ctx *gin.Context, old DBModel, s DBModel) error {
+	if hook, ok := s.(AfterUpdateHook); ok {
+		if err := hook.AfterUpdateResource(ctx, old); err != nil {
+			return err
+		}
+	}
+	if h.hooks.AfterUpdate != nil {
+		return h.hooks.AfterUpdate(ctx, old, s)
+	}
+	return nil
+}
+
+func (h *handlers) beforeDelete(ctx *gin.Context, s DBModel) error {
+	if h.hooks.BeforeDelete != nil {
+		if err := h.hooks.BeforeDelete(ctx, s); err != nil {
+			return err
+		}
+	}
+	if hook, ok := s.(BeforeDeleteHook); ok {
+		return hook.BeforeDeleteResource(ctx)
+	}
+	return nil
+}
+
+func (h *handlers) afterDelete(ctx *gin.Context, s DBModel) error {
+	if hook, ok := s.(AfterDeleteHook); ok {
+		if err := hook.AfterDeleteResource(ctx); err != nil {
+			return err
+		}
+	}
+	if h.hooks.AfterDelete != nil {
+		return h.hooks.AfterDelete(ctx, s)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package resources_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theplant/resources"
+)
+
+type hookedResource struct {
+	Resource
+}
+
+func (r *hookedResource) BeforeCreateResource(ctx *gin.Context, user resources.User, parent resources.DBModel) error {
+	r.Count = 5
+	return nil
+}
+
+func (r *hookedResource) BeforeUpdateResource(ctx *gin.Context, old resources.DBModel) error {
+	if r.Text == "forbidden" {
+		return resources.ErrForbidden
+	}
+	r.Count = old.(*hookedResource).Count + 1
+	return nil
+}
+
+func TestHooks(t *testing.T) {
+	assertNoErr(db.AutoMigrate(&hookedResource{}).Error)
+
+	u := User{}
+	assertNoErr(db.Save(&u).Error)
+
+	calls := []string{}
+	record := func(name string) func(*gin.Context, resources.DBModel) error {
+		return func(*gin.Context, resources.DBModel) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	hooked := resources.NewWithOptions(db,
+		func() resources.DBModel { return &hookedResource{} },
+		resources.WithHooks(resources.Hooks{
+			BeforeCreate: func(ctx *gin.Context, s resources.DBModel, user resources.User, parent resources.DBModel) error {
+				calls = append(calls, "BeforeCreate")
+				return nil
+			},
+			AfterCreate: func(ctx *gin.Context, s resources.DBModel, user resources.User, parent resources.DBModel) error {
+				calls = append(calls, "AfterCreate")
+				return nil
+			},
+			BeforeUpdate: func(ctx *gin.Context, old resources.DBModel, updated resources.DBModel) error {
+				calls = append(calls, "BeforeUpdate")
+				return nil
+			},
+			AfterUpdate: func(ctx *gin.Context, old resources.DBModel, updated resources.DBModel) error {
+				calls = append(calls, fmt.Sprintf("AfterUpdate %d->%d", old.(*hookedResource).Count, updated.(*hookedResource).Count))
+				return nil
+			},
+			BeforeDelete: record("BeforeDelete"),
+			AfterDelete:  record("AfterDelete"),
+		}))
+
+	router = gin.New()
+	router.POST("/r", func(ctx *gin.Context) {
+		hooked.Post(ctx, &u, &u)
+	})
+	router.PATCH("/r/:id", hooked.ProvideModel(hooked.Patch))
+	router.DELETE("/r/:id", hooked.ProvideModel(hooked.Delete))
+
+	resp := doRequest(t, "POST", "/r", strings.NewReader(`{"Text": "created"}`))
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Error POSTing hooked resource\nexpected %d, got %d: %v", http.StatusCreated, resp.Code, resp)
+	}
+
+	r := &hookedResource{}
+	assertNoErr(db.Where("user_id = ?", u.ID).First(r).Error)
+	if r.Count != 5 {
+		t.Fatalf("Change made by before create hook not saved: %v", r.Count)
+	}
+	path := fmt.Sprintf("/r/%d", r.ID)
+
+	tests := []struct {
+		Method string
+		Body   string
+		Code   int
+	}{
+		{"PATCH", `{"Text": "forbidden"}`, http.StatusForbidden},
+		{"PATCH", `{"Text": "changed"}`, http.StatusOK},
+		{"DELETE", "", http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		resp := doRequest(t, test.Method, path, strings.NewReader(test.Body))
+		if resp.Code != test.Code {
+			t.Fatalf("Error %s %s\nexpected %d, got %d: %v", test.Method, test.Body, test.Code, resp.Code, resp)
+		}
+
+		if test.Code == http.StatusOK {
+			assertNoErr(db.Where("id = ?", r.ID).First(r).Error)
+			if r.Text != "changed" || r.Count != 6 {
+				t.Fatalf("Wrong result of PATCH with hooks: '%v', %v", r.Text, r.Count)
+			}
+		}
+	}
+
+	expected := "BeforeCreate,AfterCreate,BeforeUpdate,BeforeUpdate,AfterUpdate 5->6,BeforeDelete,AfterDelete"
+	if got := strings.Join(calls, ","); got != expected {
+		t.Fatalf("Wrong hooks called:\nexpected: '%v'\ngot:      '%v'", expected, got)
+	}
+}
@@ -0,0 +1,192 @@
+package resources
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// IDCodec converts between the IDs that identify models in URLs (and
+// in the bodies of bulk requests) and the values of a DB column (see
+// WithIDCodec). Models are identified by a single column, so models
+// with composite keys need a unique column to be identified by.
+//
+// Keyset pagination doesn't use the IDCodec: cursors always page by
+// the primary key, so collections of models without an integer
+// primary key (eg. UUIDIDs{} models) respond to `cursor` parameters
+// with ErrCursorUnsupported.
+type IDCodec interface {
+	// IDColumn is the DB column that models are identified by, or
+	// "" for the primary key.
+	IDColumn() string
+
+	// Parse validates an ID, eg. from a URL param, and returns it as
+	// it's stored in the ID column. It returns gorm.ErrRecordNotFound
+	// if `id` can't be an ID, so that handlers respond with 404.
+	Parse(id string) (string, error)
+
+	// Format returns the ID of a model with the value `value` in the
+	// ID column.
+	Format(value interface{}) (string, error)
+}
+
+// NumericIDs identifies models by an unsigned integer primary key. It
+// is the default IDCodec.
+type NumericIDs struct{}
+
+// IDColumn is the primary key.
+func (NumericIDs) IDColumn() string {
+	return ""
+}
+
+// Parse accepts decimal integers.
+func (NumericIDs) Parse(id string) (string, error) {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return "", gorm.ErrRecordNotFound
+	}
+	return strconv.FormatUint(n, 10), nil
+}
+
+// Format formats the value as a decimal integer.
+func (NumericIDs) Format(value interface{}) (string, error) {
+	return fmt.Sprint(value), nil
+}
+
+var regexpUUID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// UUIDIDs identifies models by a UUID, stored as a string (or a type
+// with a `String` method) in Column, or in the primary key if Column
+// is "".
+type UUIDIDs struct {
+	Column string
+}
+
+// IDColumn is Column.
+func (codec UUIDIDs) IDColumn() string {
+	return codec.Column
+}
+
+// Parse accepts UUIDs in their canonical form, in either case, and
+// returns them in lower case.
+func (UUIDIDs) Parse(id string) (string, error) {
+	if !regexpUUID.MatchString(id) {
+		return "", gorm.ErrRecordNotFound
+	}
+	return strings.ToLower(id), nil
+}
+
+// Format formats the value in lower case.
+func (UUIDIDs) Format(value interface{}) (string, error) {
+	return strings.ToLower(fmt.Sprint(value)), nil
+}
+
+var regexpSlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// SlugIDs identifies models by a slug, eg. `my-first-post`, made of
+// lower case letters and digits separated by dashes, stored in
+// Column.
+type SlugIDs struct {
+	Column string
+}
+
+// IDColumn is Column.
+func (codec SlugIDs) IDColumn() string {
+	return codec.Column
+}
+
+// Parse accepts slugs.
+func (SlugIDs) Parse(id string) (string, error) {
+	if !regexpSlug.MatchString(id) {
+		return "", gorm.ErrRecordNotFound
+	}
+	return id, nil
+}
+
+// Format returns the value as it is.
+func (SlugIDs) Format(value interface{}) (string, error) {
+	return fmt.Sprint(value), nil
+}
+
+// patternIDs identifies models by a primary key matching a pattern
+// (see WithIDPattern).
+type patternIDs struct {
+	pattern *regexp.Regexp
+}
+
+func (patternIDs) IDColumn() string {
+	return ""
+}
+
+func (codec patternIDs) Parse(id string) (string, error) {
+	if !codec.pattern.MatchString(id) {
+		return "", gorm.ErrRecordNotFound
+	}
+	return id, nil
+}
+
+func (patternIDs) Format(value interface{}) (string, error) {
+	return fmt.Sprint(value), nil
+}
+
+// idColumn returns the DB column of the resource's IDs.
+func (h *handlers) idColumn() string {
+	if column := h.idCodec.IDColumn(); column != "" {
+		return column
+	}
+	return h.db.NewScope(h.single()).PrimaryKey()
+}
+
+// whereID parses `id` with the resource's IDCodec, and returns a scope
+// that finds the model it identifies.
+func (h *handlers) whereID(id string) (func(*gorm.DB) *gorm.DB, error) {
+	value, err := h.idCodec.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := h.db.NewScope(h.single())
+	where := fmt.Sprintf("%s.%s = ?", scope.QuotedTableName(), scope.Quote(h.idColumn()))
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(where, value)
+	}, nil
+}
+
+// formatID returns the ID of `s`, as formatted by the resource's
+// IDCodec.
+func (h *handlers) formatID(s DBModel) (string, error) {
+	field, ok := h.db.NewScope(s).FieldByName(h.idColumn())
+	if !ok {
+		return "", fmt.Errorf("%T has no ID column %q", s, h.idColumn())
+	}
+	return h.idCodec.Format(field.Field.Interface())
+}
+
+// setID sets the ID of `s` to `id`, as given in a URL.
+func (h *handlers) setID(s DBModel, id string) error {
+	value, err := h.idCodec.Parse(id)
+	if err != nil {
+		return err
+	}
+	return setColumn(h.db, s, h.idColumn(), value)
+}
+
+// link returns the URL of `s` from the resource's linker, or "" if it
+// has no linker.
+func (h *handlers) link(ctx *gin.Context, s DBModel) (string, error) {
+	switch {
+	case h.stringLinker != nil:
+		id, err := h.formatID(s)
+		if err != nil {
+			return "", err
+		}
+		return absURL(ctx.Request, h.stringLinker(id))
+	case h.linker != nil:
+		return absURL(ctx.Request, h.linker(s.GetID()))
+	}
+	return "", nil
+}
@@ -0,0 +1,192 @@
+package resources_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theplant/resources"
+)
+
+type sluggedResource struct {
+	Resource
+
+	Slug string
+}
+
+type uuidResource struct {
+	ID     string `gorm:"primary_key"`
+	UserID uint
+	Text   string
+}
+
+func (r *uuidResource) GetID() uint {
+	return 0
+}
+
+func (r *uuidResource) OwnerID() uint {
+	return r.UserID
+}
+
+func (r *uuidResource) SetOwner(user resources.User) error {
+	r.UserID = user.GetID()
+	return nil
+}
+
+func (r *uuidResource) ParentID() uint {
+	return r.UserID
+}
+
+func (r *uuidResource) SetParent(model resources.DBModel) error {
+	r.UserID = model.GetID()
+	return nil
+}
+
+func TestIDCodecParse(t *testing.T) {
+	tests := []struct {
+		Codec resources.IDCodec
+		ID    string
+		// Expected parsed ID, or "" if invalid
+		Parsed string
+	}{
+		{resources.NumericIDs{}, "12", "12"},
+		{resources.NumericIDs{}, "012", "12"},
+		{resources.NumericIDs{}, "-1", ""},
+		{resources.NumericIDs{}, "x12", ""},
+		{resources.UUIDIDs{}, "3F2504E0-4F89-11D3-9A0C-0305E82C3301", "3f2504e0-4f89-11d3-9a0c-0305e82c3301"},
+		{resources.UUIDIDs{}, "3f2504e04f8911d39a0c0305e82c3301", ""},
+		{resources.UUIDIDs{}, "12", ""},
+		{resources.SlugIDs{Column: "slug"}, "my-first-post", "my-first-post"},
+		{resources.SlugIDs{Column: "slug"}, "My-First-Post", ""},
+		{resources.SlugIDs{Column: "slug"}, "my--post", ""},
+		{resources.SlugIDs{Column: "slug"}, "-post", ""},
+	}
+
+	for _, test := range tests {
+		parsed, err := test.Codec.Parse(test.ID)
+		if test.Parsed == "" {
+			if err == nil {
+				t.Fatalf("%T parsed invalid ID '%v' as '%v'", test.Codec, test.ID, parsed)
+			}
+			continue
+		}
+		if err != nil || parsed != test.Parsed {
+			t.Fatalf("Error parsing '%v' with %T\nexpected: '%v'\ngot:      '%v' (%v)", test.ID, test.Codec, test.Parsed, parsed, err)
+		}
+	}
+}
+
+func TestIDCodecSlugs(t *testing.T) {
+	assertNoErr(db.DropTableIfExists(&sluggedResource{}).Error)
+	assertNoErr(db.AutoMigrate(&sluggedResource{}).Error)
+
+	u := User{}
+	assertNoErr(db.Save(&u).Error)
+	r := sluggedResource{Resource: Resource{Text: "text", UserID: u.ID}, Slug: "first-post"}
+	assertNoErr(db.Save(&r).Error)
+
+	slugged := resources.NewWithOptions(db,
+		func() resources.DBModel { return &sluggedResource{} },
+		resources.WithIDCodec(resources.SlugIDs{Column: "slug"}),
+		resources.WithStringLinker(func(id string) string {
+			return "/r/" + id
+		}),
+		resources.WithSerializers(resources.JSONSerializer{}, resources.JSONAPISerializer{}))
+
+	router = gin.New()
+	router.GET("/r/:id", slugged.ProvideModel(func(c *gin.Context, s resources.DBModel) {
+		c.String(http.StatusOK, "%d", s.GetID())
+	}))
+
+	tests := []struct {
+		Code int
+		ID   string
+	}{
+		{http.StatusOK, "first-post"},
+		{http.StatusNotFound, "second-post"},
+		{http.StatusNotFound, fmt.Sprint(r.ID)},
+		{http.StatusNotFound, "First-Post"},
+	}
+
+	for _, test := range tests {
+		path := fmt.Sprintf("/r/%s", test.ID)
+		resp := doRequest(t, "GET", path, nil)
+
+		if resp.Code != test.Code {
+			t.Fatalf("Error finding resource at %s, expected %d, got %d: %v", path, test.Code, resp.Code, resp)
+		}
+		if resp.Code == http.StatusOK && resp.Body.String() != fmt.Sprint(r.ID) {
+			t.Fatalf("Wrong resource found at %s\nexpected: '%v'\ngot:      '%v'", path, r.ID, resp.Body.String())
+		}
+	}
+
+	resp := mountOwnerParentHandler(t, &u, &u, slugged.Post)(postBody(t, struct{ Text, Slug string }{"text", "second-post"}))
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Error POSTing slugged resource\nexpected %d, got %d: %v", http.StatusCreated, resp.Code, resp)
+	}
+	if location := resp.Header().Get("Location"); location != "/r/second-post" {
+		t.Fatalf("Wrong Location header for slugged resource: '%v'", location)
+	}
+
+	// The ID column is selected along with the requested fields
+	req, err := http.NewRequest("GET", "/test?fields=Text&limit=1", nil)
+	assertNoErr(err)
+	req.Header.Set("Accept", "application/vnd.api+json")
+	resp = httptest.NewRecorder()
+	mountOwnerHandler(t, &u, slugged.Collection)
+	router.ServeHTTP(resp, req)
+
+	var doc struct {
+		Data []struct {
+			ID    string
+			Links struct{ Self string }
+		}
+	}
+	assertNoErr(json.Unmarshal(resp.Body.Bytes(), &doc))
+	if len(doc.Data) != 1 || doc.Data[0].ID != "first-post" || doc.Data[0].Links.Self != "/r/first-post" {
+		t.Fatalf("Wrong ID of slugged resource with sparse fields: %v", resp.Body)
+	}
+}
+
+func TestIDCodecUUIDs(t *testing.T) {
+	assertNoErr(db.DropTableIfExists(&uuidResource{}).Error)
+	assertNoErr(db.AutoMigrate(&uuidResource{}).Error)
+
+	u := User{}
+	assertNoErr(db.Save(&u).Error)
+	r := uuidResource{ID: "3f2504e0-4f89-11d3-9a0c-0305e82c3301", UserID: u.ID, Text: "text"}
+	assertNoErr(db.Save(&r).Error)
+
+	uuids := resources.NewWithOptions(db,
+		func() resources.DBModel { return &uuidResource{} },
+		resources.WithIDCodec(resources.UUIDIDs{}))
+
+	router = gin.New()
+	router.GET("/r/:id", uuids.ProvideModel(uuids.Get))
+	if resp := doRequest(t, "GET", "/r/3F2504E0-4F89-11D3-9A0C-0305E82C3301", nil); resp.Code != http.StatusOK {
+		t.Fatalf("Error finding resource by UUID\nexpected %d, got %d: %v", http.StatusOK, resp.Code, resp)
+	}
+
+	tests := []struct {
+		Path string
+		Code int
+	}{
+		{"/test", http.StatusOK},
+		{"/test?offset=1", http.StatusOK},
+		// Cursors page by integer primary keys
+		{"/test?cursor=", http.StatusBadRequest},
+		{"/test?cursor=YTox", http.StatusBadRequest},
+	}
+
+	mountOwnerHandler(t, &u, uuids.Collection)
+	for _, test := range tests {
+		resp := doRequest(t, "GET", test.Path, nil)
+		if resp.Code != test.Code {
+			t.Fatalf("Error GETting %s\nexpected %d, got %d: %v", test.Path, test.Code, resp.Code, resp)
+		}
+	}
+}
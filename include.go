@@ -0,0 +1,86 @@
+package resources
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// DefaultMaxIncludeDepth is the default maximum number of
+// associations in an `include` path, eg. 2 for `comments.author`.
+const DefaultMaxIncludeDepth = 2
+
+// IncludeError is returned for an `include` parameter with a path
+// that can't be included.
+type IncludeError struct {
+	Path    string
+	Message string
+}
+
+func (err IncludeError) Error() string {
+	return fmt.Sprintf("can't include %q: %s", err.Path, err.Message)
+}
+
+// parseIncludes parses the `include` query parameter of the request,
+// eg. `?include=user,comments.author`, into the Gorm association paths
+// to preload, eg. `User` and `Comments.Author`. Paths are matched
+// case-insensitively against the resource's includes (see
+// WithIncludes), and can be any prefix of an allowed path.
+func (h *handlers) parseIncludes(ctx *gin.Context) ([]string, error) {
+	param := ctx.Request.URL.Query().Get("include")
+	if param == "" {
+		return nil, nil
+	}
+
+	paths := []string{}
+	for _, requested := range strings.Split(param, ",") {
+		requested = strings.TrimSpace(requested)
+		if requested == "" {
+			continue
+		}
+
+		segments := strings.Split(requested, ".")
+		if len(segments) > h.maxIncludeDepth {
+			return nil, IncludeError{Path: requested, Message: fmt.Sprintf("can't be nested more than %d deep", h.maxIncludeDepth)}
+		}
+
+		path := matchInclude(h.includes, segments)
+		if path == "" {
+			return nil, IncludeError{Path: requested, Message: "isn't an association that can be included"}
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// matchInclude returns the prefix of the first of `includes` that
+// matches `segments`, or "" if none match.
+func matchInclude(includes []string, segments []string) string {
+	for _, include := range includes {
+		allowed := strings.Split(include, ".")
+		if len(allowed) < len(segments) {
+			continue
+		}
+
+		matched := true
+		for i, segment := range segments {
+			matched = matched && strings.EqualFold(allowed[i], segment)
+		}
+		if matched {
+			return strings.Join(allowed[:len(segments)], ".")
+		}
+	}
+	return ""
+}
+
+// includeScope returns a Gorm scope that preloads `paths`.
+func includeScope(paths []string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, path := range paths {
+			db = db.Preload(path)
+		}
+		return db
+	}
+}
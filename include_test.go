@@ -0,0 +1,96 @@
+package resources_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theplant/resources"
+)
+
+func TestIncludes(t *testing.T) {
+	u, rs := createOwnedResources(t, 2)
+
+	included := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithIncludes("User"))
+	shallow := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithIncludes("User"),
+		resources.WithMaxIncludeDepth(0))
+
+	tests := []struct {
+		Resource resources.Resource
+		Path     string
+		Code     int
+		// ID of the included user, or 0 if not included
+		UserID uint
+	}{
+		{included, fmt.Sprintf("/r/%d", rs[0].ID), http.StatusOK, 0},
+		{included, fmt.Sprintf("/r/%d?include=user", rs[0].ID), http.StatusOK, u.ID},
+		{included, fmt.Sprintf("/r/%d?include=user&fields=ID,User", rs[0].ID), http.StatusOK, u.ID},
+		{included, fmt.Sprintf("/r/%d?include=comments", rs[0].ID), http.StatusBadRequest, 0},
+		{included, fmt.Sprintf("/r/%d?include=user.resources", rs[0].ID), http.StatusBadRequest, 0},
+		{included, "/r?include=User", http.StatusOK, u.ID},
+		{included, "/r?include=User&fields=Text,User", http.StatusOK, u.ID},
+		{res, fmt.Sprintf("/r/%d?include=user", rs[0].ID), http.StatusBadRequest, 0},
+		{shallow, fmt.Sprintf("/r/%d?include=user", rs[0].ID), http.StatusBadRequest, 0},
+	}
+
+	for _, test := range tests {
+		r := test.Resource
+		router = gin.New()
+		router.GET("/r", func(ctx *gin.Context) {
+			r.Collection(ctx, &u)
+		})
+		router.GET("/r/:id", r.ProvideModel(r.Get))
+
+		resp := doRequest(t, "GET", test.Path, nil)
+		if resp.Code != test.Code {
+			t.Fatalf("Error GET %s\nexpected %d, got %d: %v", test.Path, test.Code, resp.Code, resp)
+		}
+		if resp.Code != http.StatusOK {
+			continue
+		}
+
+		var items []Resource
+		if err := json.Unmarshal(resp.Body.Bytes(), &items); err != nil {
+			items = []Resource{{}}
+			assertNoErr(json.Unmarshal(resp.Body.Bytes(), &items[0]))
+		}
+		for _, item := range items {
+			if item.User.ID != test.UserID {
+				t.Fatalf("Wrong user included in response to GET %s:\nexpected: %d\ngot:      %d", test.Path, test.UserID, item.User.ID)
+			}
+		}
+	}
+}
+
+func TestIncludesNotSaved(t *testing.T) {
+	u, rs := createOwnedResources(t, 1)
+	saved := &User{}
+	assertNoErr(db.Where("id = ?", u.ID).Find(saved).Error)
+
+	included := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithIncludes("User"))
+
+	router = gin.New()
+	router.PATCH("/r/:id", included.ProvideModel(included.Patch))
+
+	path := fmt.Sprintf("/r/%d?include=user", rs[0].ID)
+	resp := doRequest(t, "PATCH", path, strings.NewReader(`{"Text": "changed"}`))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Error PATCH %s\nexpected %d, got %d: %v", path, http.StatusOK, resp.Code, resp)
+	}
+
+	reloaded := &User{}
+	assertNoErr(db.Where("id = ?", u.ID).Find(reloaded).Error)
+	if !reloaded.UpdatedAt.Equal(saved.UpdatedAt) {
+		t.Fatalf("Included user was saved by PATCH %s:\nexpected: %v\ngot:      %v", path, saved.UpdatedAt, reloaded.UpdatedAt)
+	}
+}
@@ -0,0 +1,309 @@
+package resources
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Media types of the patch documents supported by Patch, in
+// addition to plain JSON objects.
+const (
+	// MediaTypeMergePatch is a JSON Merge Patch, as described by
+	// RFC 7396.
+	MediaTypeMergePatch = "application/merge-patch+json"
+
+	// MediaTypeJSONPatch is a JSON Patch, as described by RFC 6902.
+	MediaTypeJSONPatch = "application/json-patch+json"
+)
+
+// ErrPatchTestFailed is returned when a `test` operation of a JSON
+// Patch doesn't match the resource. Patch responds to it with 409.
+var ErrPatchTestFailed = errors.New("patch test operation failed")
+
+// PatchError is returned for a JSON Patch operation that can't be
+// applied to a resource, eg. because its path doesn't exist. Patch
+// responds to it with HTTPStatusUnprocessableEntity.
+type PatchError struct {
+	// Index of the operation in the patch document
+	Index   int
+	Message string
+}
+
+func (err *PatchError) Error() string {
+	return fmt.Sprintf("can't apply patch operation %d: %s", err.Index, err.Message)
+}
+
+// jsonPatchOp is a single operation of a JSON Patch document.
+type jsonPatchOp struct {
+	Op    string           `json:"op"`
+	Path  *string          `json:"path"`
+	From  *string          `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// decodeJSON decodes `b` into generic JSON values, keeping numbers as
+// json.Number so that they survive re-encoding unchanged.
+func decodeJSON(b []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+// mergePatch applies the JSON Merge Patch `patch` to `doc`, as
+// described by RFC 7396.
+func mergePatch(doc, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	d, ok := doc.(map[string]interface{})
+	if !ok {
+		d = map[string]interface{}{}
+	}
+
+	for k, v := range p {
+		if v == nil {
+			delete(d, k)
+		} else {
+			d[k] = mergePatch(d[k], v)
+		}
+	}
+	return d
+}
+
+// jsonPatch applies the JSON Patch operations `ops` to `doc`, as
+// described by RFC 6902. The `add`, `remove`, `replace`, `move`,
+// `copy` and `test` operations are supported.
+func jsonPatch(doc interface{}, ops []jsonPatchOp) (interface{}, error) {
+	for i, op := range ops {
+		if op.Path == nil {
+			return nil, &PatchError{Index: i, Message: "missing path"}
+		}
+		path, err := parsePointer(*op.Path)
+		if err != nil {
+			return nil, &PatchError{Index: i, Message: err.Error()}
+		}
+
+		var value interface{}
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, &PatchError{Index: i, Message: "missing value"}
+			}
+			if err := decodeJSON(*op.Value, &value); err != nil {
+				return nil, &PatchError{Index: i, Message: err.Error()}
+			}
+		case "move", "copy":
+			if op.From == nil {
+				return nil, &PatchError{Index: i, Message: "missing from"}
+			}
+			from, err := parsePointer(*op.From)
+			if err != nil {
+				return nil, &PatchError{Index: i, Message: err.Error()}
+			}
+			if value, err = pointerGet(doc, from); err != nil {
+				return nil, &PatchError{Index: i, Message: err.Error()}
+			}
+			if op.Op == "move" {
+				if doc, err = pointerRemove(doc, from); err != nil {
+					return nil, &PatchError{Index: i, Message: err.Error()}
+				}
+			}
+		}
+
+		switch op.Op {
+		case "add", "move", "copy":
+			doc, err = pointerAdd(doc, path, value)
+		case "remove":
+			doc, err = pointerRemove(doc, path)
+		case "replace":
+			if doc, err = pointerRemove(doc, path); err == nil {
+				doc, err = pointerAdd(doc, path, value)
+			}
+		case "test":
+			var current interface{}
+			if current, err = pointerGet(doc, path); err == nil && !jsonEqual(current, value) {
+				return nil, ErrPatchTestFailed
+			}
+		default:
+			return nil, &PatchError{Index: i, Message: fmt.Sprintf("unknown operation %q", op.Op)}
+		}
+		if err != nil {
+			return nil, &PatchError{Index: i, Message: err.Error()}
+		}
+	}
+	return doc, nil
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped
+// reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid path %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+func pointerGet(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch d := doc.(type) {
+		case map[string]interface{}:
+			v, ok := d[token]
+			if !ok {
+				return nil, fmt.Errorf("path %q doesn't exist", token)
+			}
+			doc = v
+		case []interface{}:
+			i, err := arrayIndex(token, len(d)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = d[i]
+		default:
+			return nil, fmt.Errorf("path %q doesn't exist", token)
+		}
+	}
+	return doc, nil
+}
+
+func pointerAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := pointerGet(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[token] = value
+		return doc, nil
+	case []interface{}:
+		i := len(p)
+		if token != "-" {
+			if i, err = arrayIndex(token, len(p)); err != nil {
+				return nil, err
+			}
+		}
+		a := append(p[:i:i], append([]interface{}{value}, p[i:]...)...)
+		return pointerSet(doc, path[:len(path)-1], a)
+	}
+	return nil, fmt.Errorf("path %q doesn't exist", token)
+}
+
+func pointerRemove(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+
+	parent, err := pointerGet(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := p[token]; !ok {
+			return nil, fmt.Errorf("path %q doesn't exist", token)
+		}
+		delete(p, token)
+		return doc, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(p)-1)
+		if err != nil {
+			return nil, err
+		}
+		a := append(p[:i:i], p[i+1:]...)
+		return pointerSet(doc, path[:len(path)-1], a)
+	}
+	return nil, fmt.Errorf("path %q doesn't exist", token)
+}
+
+// pointerSet replaces the value at `path`, which must exist.
+func pointerSet(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := pointerGet(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[token] = value
+	case []interface{}:
+		i, err := arrayIndex(token, len(p)-1)
+		if err != nil {
+			return nil, err
+		}
+		p[i] = value
+	}
+	return doc, nil
+}
+
+func arrayIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return i, nil
+}
+
+// jsonEqual compares two generic JSON values, treating numbers as
+// equal if they have the same value.
+func jsonEqual(a, b interface{}) bool {
+	an, aok := a.(json.Number)
+	bn, bok := b.(json.Number)
+	if aok && bok {
+		af, aerr := an.Float64()
+		bf, berr := bn.Float64()
+		return aerr == nil && berr == nil && af == bf
+	}
+
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			if bv, ok := b[k]; !ok || !jsonEqual(v, bv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,68 @@
+package resources_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theplant/resources"
+)
+
+func TestPatchMediaTypes(t *testing.T) {
+	r := Resource{Text: "original", Count: 5}
+	assertNoErr(db.Save(&r).Error)
+
+	router = gin.New()
+	router.PATCH("/test", func(ctx *gin.Context) {
+		reloaded := &Resource{}
+		assertNoErr(db.Where("id = ?", r.ID).Find(reloaded).Error)
+		res.Patch(ctx, reloaded)
+	})
+
+	tests := []struct {
+		ContentType string
+		Body        string
+		Code        int
+		Text        string
+		Count       int
+	}{
+		{resources.MediaTypeMergePatch, `{"Text": "merged", "Count": null}`, http.StatusOK, "merged", 0},
+		{resources.MediaTypeMergePatch, `{"Count": 2}`, http.StatusOK, "merged", 2},
+		{resources.MediaTypeMergePatch, `{"User": {"ID": 100}}`, resources.HTTPStatusUnprocessableEntity, "merged", 2},
+		{resources.MediaTypeMergePatch, `{"Text": null}`, resources.HTTPStatusUnprocessableEntity, "merged", 2},
+		{resources.MediaTypeMergePatch, `["Text"]`, resources.HTTPStatusUnprocessableEntity, "merged", 2},
+
+		{resources.MediaTypeJSONPatch, `[{"op": "test", "path": "/Text", "value": "merged"}, {"op": "replace", "path": "/Count", "value": 3}]`, http.StatusOK, "merged", 3},
+		{resources.MediaTypeJSONPatch, `[{"op": "test", "path": "/Text", "value": "other"}, {"op": "replace", "path": "/Count", "value": 4}]`, http.StatusConflict, "merged", 3},
+		{resources.MediaTypeJSONPatch, `[{"op": "test", "path": "/Count", "value": 3}, {"op": "add", "path": "/Text", "value": "patched"}]`, http.StatusOK, "patched", 3},
+		{resources.MediaTypeJSONPatch, `[{"op": "remove", "path": "/Count"}]`, http.StatusOK, "patched", 0},
+		{resources.MediaTypeJSONPatch, `[{"op": "replace", "path": "/Missing", "value": 1}]`, resources.HTTPStatusUnprocessableEntity, "patched", 0},
+		{resources.MediaTypeJSONPatch, `[{"op": "frobnicate", "path": "/Count"}]`, resources.HTTPStatusUnprocessableEntity, "patched", 0},
+		{resources.MediaTypeJSONPatch, `{"op": "remove", "path": "/Count"}`, resources.HTTPStatusUnprocessableEntity, "patched", 0},
+
+		{"application/json; charset=utf-8", `{"Count": 1}`, http.StatusOK, "patched", 1},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("PATCH", "/test", strings.NewReader(test.Body))
+		assertNoErr(err)
+		req.Header.Set("Content-Type", test.ContentType)
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != test.Code {
+			t.Fatalf("Error PATCHing %s %s\nexpected %d, got %d: %v", test.ContentType, test.Body, test.Code, resp.Code, resp)
+		}
+
+		reloaded := &Resource{}
+		assertNoErr(db.Where("id = ?", r.ID).Find(reloaded).Error)
+
+		if reloaded.Text != test.Text || reloaded.Count != test.Count {
+			t.Fatalf("Wrong result PATCHing %s %s\nexpected: '%v', '%v'\ngot:      '%v', '%v'", test.ContentType, test.Body, test.Text, test.Count, reloaded.Text, reloaded.Count)
+		}
+	}
+}
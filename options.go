@@ -0,0 +1,317 @@
+package resources
+
+import (
+	"reflect"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Option configures a Resource created with NewWithOptions.
+type Option func(*options)
+
+// options is the per-resource configuration shared by the handlers
+// of a Resource.
+type options struct {
+	linker               func(id uint) string
+	collection           func() interface{}
+	collectionOptions    CollectionOptions
+	errorMappers         ErrorMappers
+	stringLinker         func(id string) string
+	idCodec              IDCodec
+	errorSink            ErrorSink
+	requestID            func(*gin.Context) string
+	createOnPut          bool
+	versionColumn        string
+	requirePreconditions bool
+	cacheControl         string
+	policy               Policy
+	fieldMode            FieldMode
+	createFields         []string
+	updateFields         []string
+	viewFields           []string
+	includes             []string
+	maxIncludeDepth      int
+	serializers          []Serializer
+	hooks                Hooks
+	transactions         bool
+	bulkMode             BulkMode
+}
+
+// WithLinker sets the function used to build the `Location` header
+// of a newly created resource. Without a linker, Post doesn't set a
+// `Location` header.
+func WithLinker(linker func(id uint) string) Option {
+	return func(o *options) {
+		o.linker = linker
+	}
+}
+
+// WithCollection sets the function used to create the slice that
+// Collection handlers find resources into. It should return a slice
+// (or pointer to a slice) of the type returned by `single`, which is
+// also the default.
+func WithCollection(collection func() interface{}) Option {
+	return func(o *options) {
+		o.collection = collection
+	}
+}
+
+// WithCollectionOptions sets the pagination, filtering and sorting
+// options of `Resource.Collection`. The default is
+// DefaultCollectionOptions.
+func WithCollectionOptions(opts CollectionOptions) Option {
+	return func(o *options) {
+		o.collectionOptions = opts
+	}
+}
+
+// WithErrorMapper adds an ErrorMapper used by all of the resource's
+// handlers. Mappers are tried in the order they're added, before the
+// default mapping of missing records to 404.
+func WithErrorMapper(mapper ErrorMapper) Option {
+	return func(o *options) {
+		o.errorMappers = append(o.errorMappers, mapper)
+	}
+}
+
+// WithAcceptableError maps errors of type `t` to
+// HTTPStatusUnprocessableEntity. It is shorthand for
+// `WithErrorMapper(MapErrorType(t, HTTPStatusUnprocessableEntity))`.
+func WithAcceptableError(t reflect.Type) Option {
+	return WithErrorMapper(MapErrorType(t, HTTPStatusUnprocessableEntity))
+}
+
+// WithErrorSink sets the ErrorSink given the errors that handlers
+// respond to. The default is GinErrorSink.
+func WithErrorSink(sink ErrorSink) Option {
+	return func(o *options) {
+		o.errorSink = sink
+	}
+}
+
+// WithRequestID sets the function used to find the request ID
+// included in error responses. The default uses the RequestIDHeader
+// request header.
+func WithRequestID(requestID func(*gin.Context) string) Option {
+	return func(o *options) {
+		o.requestID = requestID
+	}
+}
+
+// WithCreateOnPut lets Put create resources with client-chosen IDs,
+// when no resource with the ID exists.
+func WithCreateOnPut() Option {
+	return func(o *options) {
+		o.createOnPut = true
+	}
+}
+
+// WithVersionColumn sets the DB column used for the entity tags of
+// resources. Patch and Put increment the column on every update. The
+// default is to use the `updated_at` column, which Gorm sets on every
+// update.
+func WithVersionColumn(column string) Option {
+	return func(o *options) {
+		o.versionColumn = column
+	}
+}
+
+// WithRequirePreconditions makes Patch, Put and Delete respond with
+// 428 to requests without an `If-Match` header, so that clients can't
+// overwrite changes they haven't seen.
+func WithRequirePreconditions() Option {
+	return func(o *options) {
+		o.requirePreconditions = true
+	}
+}
+
+// WithCacheControl sets the `Cache-Control` header of Get and
+// Collection responses, eg. `private, max-age=60`. By default no
+// `Cache-Control` header is set.
+func WithCacheControl(value string) Option {
+	return func(o *options) {
+		o.cacheControl = value
+	}
+}
+
+// WithPolicy sets the Policy that the resource's handlers check
+// before acting. Collection queries are restricted with the scope
+// returned by `Policy.CanList`. By default there is no policy, and
+// every action is allowed.
+func WithPolicy(policy Policy) Option {
+	return func(o *options) {
+		o.policy = policy
+	}
+}
+
+// WithUnwritableFields sets how Post, Put and Patch treat fields of
+// the request body that can't be written: fields that aren't DB
+// columns of the model, its primary key and Gorm timestamps, the
+// columns set by SetOwner and SetParent (when updating), fields
+// tagged `resources:"readonly"` (or `resources:"createonly"`, when
+// updating), and fields left out of WithCreateFields or
+// WithUpdateFields. The default is RejectFields. Put ignores these
+// fields when they have the same values as the resource it replaces.
+func WithUnwritableFields(mode FieldMode) Option {
+	return func(o *options) {
+		o.fieldMode = mode
+	}
+}
+
+// WithCreateFields sets the fields (by JSON name) that Post, and Put
+// when creating, can write. By default every field can be written,
+// apart from those described by WithUnwritableFields.
+func WithCreateFields(fields ...string) Option {
+	return func(o *options) {
+		o.createFields = fields
+	}
+}
+
+// WithUpdateFields sets the fields (by JSON name) that Patch and Put
+// can write. By default every field can be written, apart from those
+// described by WithUnwritableFields.
+func WithUpdateFields(fields ...string) Option {
+	return func(o *options) {
+		o.updateFields = fields
+	}
+}
+
+// WithView sets the fields (by JSON name) included in responses by
+// default, eg. to hide internal columns. The `fields` query parameter
+// can only select fields in the view. By default every field is
+// included.
+func WithView(fields ...string) Option {
+	return func(o *options) {
+		o.viewFields = fields
+	}
+}
+
+// WithIncludes sets the association paths (by Go field name, eg.
+// `User` or `Comments.Author`) that requests can preload with the
+// `include` query parameter. By default nothing can be included.
+func WithIncludes(paths ...string) Option {
+	return func(o *options) {
+		o.includes = paths
+	}
+}
+
+// WithMaxIncludeDepth sets the maximum number of associations in an
+// `include` path. The default is DefaultMaxIncludeDepth.
+func WithMaxIncludeDepth(depth int) Option {
+	return func(o *options) {
+		o.maxIncludeDepth = depth
+	}
+}
+
+// WithSerializers sets the serializers that render response bodies,
+// chosen by the request's `Accept` header. The first is used for
+// requests that accept any media type, and for errors when none of
+// them is acceptable. The default is JSONSerializer alone. For
+// example:
+//
+//	WithSerializers(JSONSerializer{}, JSONAPISerializer{}, HALSerializer{})
+func WithSerializers(serializers ...Serializer) Option {
+	return func(o *options) {
+		if len(serializers) > 0 {
+			o.serializers = serializers
+		}
+	}
+}
+
+// WithHooks sets the functions called around the changes made by the
+// resource's handlers.
+func WithHooks(hooks Hooks) Option {
+	return func(o *options) {
+		o.hooks = hooks
+	}
+}
+
+// WithTransactions runs Post, Patch, Put and Delete (and their hooks)
+// in a DB transaction, which is committed if the handler succeeds, and
+// rolled back if it responds with an error or panics. The response is
+// only written once the transaction has been committed.
+func WithTransactions() Option {
+	return func(o *options) {
+		o.transactions = true
+	}
+}
+
+// WithBulkMode sets how BulkPost, BulkPatch and BulkDelete respond
+// when some items fail. The default is BulkAllOrNothing.
+func WithBulkMode(mode BulkMode) Option {
+	return func(o *options) {
+		o.bulkMode = mode
+	}
+}
+
+// WithIDCodec sets the IDCodec that converts between the IDs of
+// models in URLs (and the bodies of bulk requests) and the DB. The
+// default is NumericIDs. For example:
+//
+//	WithIDCodec(SlugIDs{Column: "slug"})
+func WithIDCodec(codec IDCodec) Option {
+	return func(o *options) {
+		o.idCodec = codec
+	}
+}
+
+// WithStringLinker is like WithLinker, but the linker is given the ID
+// of the model as formatted by the resource's IDCodec. It takes
+// precedence over WithLinker.
+func WithStringLinker(linker func(id string) string) Option {
+	return func(o *options) {
+		o.stringLinker = linker
+	}
+}
+
+// WithIDPattern sets the pattern that URL params must match to be
+// looked up by ProvideModelForKey, as the primary key. It replaces the
+// resource's IDCodec.
+func WithIDPattern(pattern *regexp.Regexp) Option {
+	return func(o *options) {
+		o.idCodec = patternIDs{pattern: pattern}
+	}
+}
+
+func newOptions(single func() DBModel, opts []Option) *options {
+	o := &options{
+		collectionOptions: DefaultCollectionOptions,
+		idCodec:           NumericIDs{},
+		errorSink:         GinErrorSink,
+		requestID:         requestIDFromHeader,
+		maxIncludeDepth:   DefaultMaxIncludeDepth,
+		serializers:       []Serializer{JSONSerializer{}},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.collection == nil && single != nil {
+		o.collection = sliceOf(single)
+	}
+
+	return o
+}
+
+// sliceOf returns a collection function returning an empty slice of
+// the type returned by `single`.
+func sliceOf(single func() DBModel) func() interface{} {
+	t := reflect.TypeOf(single())
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return func() interface{} {
+		return reflect.New(reflect.SliceOf(t)).Interface()
+	}
+}
+
+func requestIDFromHeader(ctx *gin.Context) string {
+	return ctx.Request.Header.Get(RequestIDHeader)
+}
+
+// mapError maps `err` with the resource's error mappers, then the
+// default mapper.
+func (o *options) mapError(err error) (int, interface{}, bool) {
+	return ErrorMappers{o.errorMappers, defaultErrorMapper}.MapError(err)
+}
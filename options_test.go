@@ -0,0 +1,88 @@
+package resources_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theplant/resources"
+)
+
+func TestNewWithOptionsDefaults(t *testing.T) {
+	u, rs := createOwnedResources(t, 2)
+
+	r := resources.NewWithOptions(db, func() resources.DBModel { return &Resource{} })
+
+	req := mountOwnerHandler(t, &u, r.Collection)
+	if got := ids(unmarshalCollection(t, req(""))); !equalIDs(got, ids(rs)) {
+		t.Fatalf("Wrong collection with default options\nexpected: '%v'\ngot:      '%v'", ids(rs), got)
+	}
+
+	resp := mountOwnerParentHandler(t, &u, &u, r.Post)(postBody(t, struct{ Text string }{"text"}))
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Error POSTing resource without linker\nexpected %d, got %d: %v", http.StatusCreated, resp.Code, resp)
+	}
+	if location := resp.Header().Get("Location"); location != "" {
+		t.Fatalf("Unexpected Location header without linker: '%v'", location)
+	}
+}
+
+func TestNewWithOptionsAcceptableError(t *testing.T) {
+	u := User{}
+	assertNoErr(db.Save(&u).Error)
+
+	resourceError = errors.New("acceptable error")
+	defer clearResourceErrors()
+
+	accepting := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithAcceptableError(reflect.TypeOf(resourceError)))
+
+	resp := mountOwnerParentHandler(t, &u, &u, accepting.Post)(postBody(t, struct{ Text string }{"text"}))
+	if resp.Code != resources.HTTPStatusUnprocessableEntity {
+		t.Fatalf("Error POSTing resource\nexpected %d, got %d: %v", resources.HTTPStatusUnprocessableEntity, resp.Code, resp)
+	}
+
+	// Configuration of one resource doesn't affect others
+	resp = mountOwnerParentHandler(t, &u, &u, res.Post)(postBody(t, struct{ Text string }{"text"}))
+	if resp.Code != http.StatusInternalServerError {
+		t.Fatalf("Error POSTing resource\nexpected %d, got %d: %v", http.StatusInternalServerError, resp.Code, resp)
+	}
+}
+
+func TestNewWithOptionsIDPattern(t *testing.T) {
+	r := Resource{}
+	assertNoErr(db.Save(&r).Error)
+
+	slugged := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithIDPattern(regexp.MustCompile(`^\d+$`)))
+
+	router = gin.New()
+	router.GET("/r/:id", slugged.ProvideModel(func(c *gin.Context, s resources.DBModel) {
+		c.String(http.StatusOK, "OK")
+	}))
+
+	tests := []struct {
+		Code int
+		ID   string
+	}{
+		{http.StatusOK, strconv.FormatUint(uint64(r.ID), 10)},
+		{http.StatusNotFound, fmt.Sprintf("x%d", r.ID)},
+	}
+
+	for _, test := range tests {
+		path := fmt.Sprintf("/r/%s", test.ID)
+		resp := doRequest(t, "GET", path, nil)
+
+		if resp.Code != test.Code {
+			t.Fatalf("Error finding resource at %s, expected %d, got %d: %v", path, test.Code, resp.Code, resp)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package resources
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// CollectionOptions configures the handler built by
+// `Resource.CollectionWith`.
+type CollectionOptions struct {
+	// DefaultLimit is the page size used when a request doesn't give
+	// a `limit` parameter.
+	DefaultLimit int
+
+	// MaxLimit caps the page size a client can ask for. Larger
+	// `limit` parameters are silently reduced to MaxLimit.
+	MaxLimit int
+
+	// Filters are the columns, and operators on each column, that
+	// requests can filter the collection by. See FilterOperator.
+	// Other query parameters are ignored, except `column[operator]`
+	// parameters that aren't allowed, which are rejected with
+	// FilterErrors.
+	Filters Filters
+
+	// Sortable are the columns that requests can sort the collection
+	// by, with a `sort` parameter like `-created_at,text`. Sorted
+	// collections can only be paginated by `offset`: cursors only
+	// page by the primary key, so requests with both `sort` and
+	// `cursor` parameters get ErrCursorWithSort.
+	Sortable []string
+
+	// Deleted allows requests to list soft-deleted resources with a
+	// `deleted` parameter of `include` or `only`. The resource's
+	// model must have a `DeletedAt` field.
+	Deleted bool
+}
+
+// DefaultCollectionOptions are the options used for
+// `Resource.Collection`.
+var DefaultCollectionOptions = CollectionOptions{
+	DefaultLimit: 25,
+	MaxLimit:     100,
+}
+
+var (
+	// ErrInvalidLimit is returned for a `limit` parameter that isn't
+	// a positive integer.
+	ErrInvalidLimit = errors.New("limit must be a positive integer")
+
+	// ErrInvalidOffset is returned for an `offset` parameter that
+	// isn't a non-negative integer.
+	ErrInvalidOffset = errors.New("offset must be a non-negative integer")
+
+	// ErrInvalidCursor is returned for a `cursor` parameter that
+	// wasn't generated by this package.
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrCursorWithOffset is returned when a request asks for both
+	// keyset (`cursor`) and `offset` pagination.
+	ErrCursorWithOffset = errors.New("cursor and offset can't be used together")
+
+	// ErrCursorUnsupported is returned for a `cursor` parameter when
+	// the resource's model doesn't have an integer primary key, eg.
+	// when it's identified by a UUID.
+	ErrCursorUnsupported = errors.New("cursor pagination needs an integer primary key")
+)
+
+// page is a single page of a collection, as requested via the
+// `limit`, `offset`, `cursor` and `sort` query parameters.
+//
+// An empty `cursor` parameter starts keyset pagination from the
+// beginning of the collection, otherwise pages are found by offset.
+type page struct {
+	limit     int
+	offset    int
+	cursor    *cursor
+	orderings []ordering
+}
+
+// cursor is the decoded form of the opaque `cursor` parameter: a
+// position in the collection, relative to the ID of a DB model, as
+// returned by `GetID` (whatever the resource's IDCodec).
+type cursor struct {
+	before bool
+	id     uint
+}
+
+func parsePage(query url.Values, opts CollectionOptions) (page, error) {
+	p := page{limit: opts.DefaultLimit}
+
+	if s := query.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 {
+			return p, ErrInvalidLimit
+		}
+		p.limit = limit
+	}
+	if opts.MaxLimit > 0 && p.limit > opts.MaxLimit {
+		p.limit = opts.MaxLimit
+	}
+
+	if s := query.Get("offset"); s != "" {
+		offset, err := strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			return p, ErrInvalidOffset
+		}
+		p.offset = offset
+	}
+
+	if _, ok := query["cursor"]; ok {
+		if p.offset != 0 {
+			return p, ErrCursorWithOffset
+		}
+		c, err := decodeCursor(query.Get("cursor"))
+		if err != nil {
+			return p, err
+		}
+		p.cursor = &c
+	}
+
+	orderings, err := parseSort(query, opts.Sortable)
+	if err != nil {
+		return p, err
+	}
+	if len(orderings) > 0 && p.cursor != nil {
+		return p, ErrCursorWithSort
+	}
+	p.orderings = orderings
+
+	return p, nil
+}
+
+func decodeCursor(s string) (cursor, error) {
+	if s == "" {
+		return cursor{}, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(b), ":", 2)
+	if len(parts) != 2 || (parts[0] != "a" && parts[0] != "b") {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	// There are no IDs before 0
+	if parts[0] == "b" && id == 0 {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	return cursor{before: parts[0] == "b", id: uint(id)}, nil
+}
+
+// integerKey returns whether the primary key of `scope` is an integer,
+// so that its models can be paged with cursors.
+func integerKey(scope *gorm.Scope) bool {
+	field := scope.PrimaryField()
+	if field == nil {
+		return false
+	}
+
+	t := field.Struct.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func (c cursor) String() string {
+	dir := "a"
+	if c.before {
+		dir = "b"
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d", dir, c.id)))
+}
+
+// apply limits `db` to the rows of this page of the table of
+// `scope`, ordered by the page's orderings and then the primary key
+// `pk`. Keyset pages fetch one extra row, so that `fetched` can tell
+// whether there are more rows beyond the page.
+func (p page) apply(db *gorm.DB, scope *gorm.Scope, pk string) *gorm.DB {
+	if p.cursor == nil {
+		return db.Scopes(sortScope(scope, p.orderings, pk)).Offset(p.offset).Limit(p.limit)
+	}
+
+	if p.cursor.before {
+		db = db.Where(pk+" < ?", p.cursor.id).Order(pk + " DESC")
+	} else {
+		db = db.Where(pk+" > ?", p.cursor.id).Order(pk + " ASC")
+	}
+	return db.Limit(p.limit + 1)
+}
+
+// fetched trims `items` (a slice of DB models, as fetched with
+// `apply`) down to the page, and returns the links to the pages on
+// either side of it.
+func (p page) fetched(items reflect.Value, total int) (reflect.Value, pageLinks) {
+	links := pageLinks{}
+
+	if p.cursor == nil {
+		if p.offset+items.Len() < total {
+			next := p.offset + p.limit
+			links.next = &page{limit: p.limit, offset: next, orderings: p.orderings}
+		}
+		if p.offset > 0 {
+			prev := p.offset - p.limit
+			if prev < 0 {
+				prev = 0
+			}
+			links.prev = &page{limit: p.limit, offset: prev, orderings: p.orderings}
+		}
+		return items, links
+	}
+
+	more := items.Len() > p.limit
+	if more {
+		items = items.Slice(0, p.limit)
+	}
+	if p.cursor.before {
+		items = reversed(items)
+	}
+
+	var first, last cursor
+	if items.Len() > 0 {
+		first = cursor{before: true, id: modelAt(items, 0).GetID()}
+		last = cursor{id: modelAt(items, items.Len()-1).GetID()}
+	} else if p.cursor.before {
+		last = cursor{id: p.cursor.id - 1}
+	} else {
+		first = cursor{before: true, id: p.cursor.id + 1}
+	}
+
+	hasPrev, hasNext := p.cursor.id > 0, more
+	if p.cursor.before {
+		hasPrev, hasNext = more, true
+	}
+
+	if hasPrev {
+		links.prev = &page{limit: p.limit, cursor: &first}
+	}
+	if hasNext {
+		links.next = &page{limit: p.limit, cursor: &last}
+	}
+
+	return items, links
+}
+
+func (p page) query(base url.Values) url.Values {
+	q := url.Values{}
+	for k, v := range base {
+		q[k] = v
+	}
+	q.Del("offset")
+	q.Del("cursor")
+
+	q.Set("limit", strconv.Itoa(p.limit))
+	if p.cursor != nil {
+		q.Set("cursor", p.cursor.String())
+	} else if p.offset > 0 {
+		q.Set("offset", strconv.Itoa(p.offset))
+	}
+	return q
+}
+
+// pageLinks are the pages either side of a fetched page, if any.
+type pageLinks struct {
+	next *page
+	prev *page
+}
+
+// list returns the links as absolute URLs.
+func (l pageLinks) list(req *http.Request) ([]Link, error) {
+	links := []Link{}
+	for _, link := range []struct {
+		rel string
+		p   *page
+	}{{"next", l.next}, {"prev", l.prev}} {
+		if link.p == nil {
+			continue
+		}
+		u, err := absURL(req, "?"+link.p.query(req.URL.Query()).Encode())
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, Link{Rel: link.rel, Href: u})
+	}
+	return links, nil
+}
+
+// formatLinks formats `links` as a RFC 5988 `Link` header value.
+func formatLinks(links []Link) string {
+	values := make([]string, len(links))
+	for i, link := range links {
+		values[i] = fmt.Sprintf(`<%s>; rel="%s"`, link.Href, link.Rel)
+	}
+	return strings.Join(values, ", ")
+}
+
+// newCollection calls `collection`, returning a pointer to the
+// result so that it can be passed to Gorm's finders.
+func newCollection(collection func() interface{}) interface{} {
+	c := collection()
+	v := reflect.ValueOf(c)
+	if v.Kind() == reflect.Ptr {
+		return c
+	}
+
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	return ptr.Interface()
+}
+
+// modelAt returns the i'th element of a slice of DB models, whether
+// the slice holds structs or pointers to structs.
+func modelAt(items reflect.Value, i int) DBModel {
+	item := items.Index(i)
+	if item.Kind() != reflect.Ptr {
+		item = item.Addr()
+	}
+	return item.Interface().(DBModel)
+}
+
+func reversed(items reflect.Value) reflect.Value {
+	r := reflect.MakeSlice(items.Type(), items.Len(), items.Len())
+	for i := 0; i < items.Len(); i++ {
+		r.Index(items.Len() - 1 - i).Set(items.Index(i))
+	}
+	return r
+}
+
+// countRelated counts the models of `collection` related to the model
+// of `db`, as found by Related, without loading them. Related always
+// finds into `collection`, so the count is scanned into the query's
+// destination instead, as Gorm's Count does.
+func countRelated(db *gorm.DB, collection interface{}) (int, error) {
+	var result struct {
+		Count int
+	}
+	err := db.Select("count(*) AS count").Set("gorm:query_destination", &result).Related(collection).Error
+	return result.Count, err
+}
@@ -0,0 +1,215 @@
+package resources_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theplant/resources"
+)
+
+func TestCollectionOffsetPagination(t *testing.T) {
+	u, rs := createOwnedResources(t, 5)
+
+	req := mountOwnerHandler(t, &u, res.Collection)
+
+	tests := []struct {
+		Query string
+		IDs   []uint
+		Next  bool
+		Prev  bool
+	}{
+		{"limit=2", ids(rs[0:2]), true, false},
+		{"limit=2&offset=2", ids(rs[2:4]), true, true},
+		{"limit=2&offset=4", ids(rs[4:5]), false, true},
+		{"limit=2&offset=10", []uint{}, false, true},
+		{"", ids(rs), false, false},
+	}
+
+	for _, test := range tests {
+		resp := req(test.Query)
+
+		if resp.Code != http.StatusOK {
+			t.Fatalf("Error GETting collection with %q\nexpected %d, got %d: %v", test.Query, http.StatusOK, resp.Code, resp)
+		}
+
+		if total := resp.Header().Get("X-Total-Count"); total != "5" {
+			t.Fatalf("Wrong total count with %q\nexpected: '5'\ngot:      '%v'", test.Query, total)
+		}
+
+		got := ids(unmarshalCollection(t, resp))
+		if !equalIDs(got, test.IDs) {
+			t.Fatalf("Wrong page with %q\nexpected: '%v'\ngot:      '%v'", test.Query, test.IDs, got)
+		}
+
+		links := linkHeader(resp)
+		if _, ok := links["next"]; ok != test.Next {
+			t.Fatalf("Wrong next link with %q, got: '%v'", test.Query, links)
+		}
+		if _, ok := links["prev"]; ok != test.Prev {
+			t.Fatalf("Wrong prev link with %q, got: '%v'", test.Query, links)
+		}
+	}
+
+	if links := linkHeader(req("limit=2&offset=2")); links["next"].Query().Get("offset") != "4" || links["prev"].Query().Get("offset") != "" {
+		t.Fatalf("Wrong links for offset page, got: '%v'", links)
+	}
+}
+
+func TestCollectionEmpty(t *testing.T) {
+	u, _ := createOwnedResources(t, 0)
+
+	req := mountOwnerHandler(t, &u, res.Collection)
+
+	for _, query := range []string{"", "cursor="} {
+		resp := req(query)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("Error GETting empty collection with %q\nexpected %d, got %d: %v", query, http.StatusOK, resp.Code, resp)
+		}
+
+		if b := body(t, resp); b != "[]" {
+			t.Fatalf("Wrong body for empty collection with %q\nexpected: '[]'\ngot:      '%v'", query, b)
+		}
+
+		if link := resp.Header().Get("Link"); link != "" {
+			t.Fatalf("Unexpected links for empty collection with %q: '%v'", query, link)
+		}
+	}
+}
+
+func TestCollectionLimits(t *testing.T) {
+	u, _ := createOwnedResources(t, 4)
+
+	collection := res.CollectionWith(resources.CollectionOptions{DefaultLimit: 3, MaxLimit: 2})
+	req := mountOwnerHandler(t, &u, collection)
+
+	if n := len(unmarshalCollection(t, req("limit=100"))); n != 2 {
+		t.Fatalf("Didn't cap page size\nexpected: '2'\ngot:      '%v'", n)
+	}
+
+	collection = res.CollectionWith(resources.CollectionOptions{DefaultLimit: 3, MaxLimit: 10})
+	req = mountOwnerHandler(t, &u, collection)
+
+	if n := len(unmarshalCollection(t, req(""))); n != 3 {
+		t.Fatalf("Didn't use default page size\nexpected: '3'\ngot:      '%v'", n)
+	}
+
+	for _, query := range []string{"limit=0", "limit=x", "offset=-1", "cursor=not-a-cursor", "cursor=&offset=2", "cursor=" + base64.RawURLEncoding.EncodeToString([]byte("b:0"))} {
+		resp := req(query)
+		if resp.Code != http.StatusBadRequest {
+			t.Fatalf("Error GETting collection with %q\nexpected %d, got %d: %v", query, http.StatusBadRequest, resp.Code, resp)
+		}
+	}
+}
+
+func TestCollectionCursorPagination(t *testing.T) {
+	u, rs := createOwnedResources(t, 5)
+
+	req := mountOwnerHandler(t, &u, res.Collection)
+
+	resp := req("limit=2&cursor=")
+	if got := ids(unmarshalCollection(t, resp)); !equalIDs(got, ids(rs[0:2])) {
+		t.Fatalf("Wrong first page\nexpected: '%v'\ngot:      '%v'", ids(rs[0:2]), got)
+	}
+	if _, ok := linkHeader(resp)["prev"]; ok {
+		t.Fatalf("Unexpected prev link on first page")
+	}
+
+	// Delete the row the next cursor points at
+	next := linkHeader(resp)["next"]
+	assertNoErr(db.Delete(&rs[1]).Error)
+
+	resp = req(next.RawQuery)
+	if got := ids(unmarshalCollection(t, resp)); !equalIDs(got, ids(rs[2:4])) {
+		t.Fatalf("Wrong page after deleted cursor\nexpected: '%v'\ngot:      '%v'", ids(rs[2:4]), got)
+	}
+
+	links := linkHeader(resp)
+	resp = req(links["next"].RawQuery)
+	if got := ids(unmarshalCollection(t, resp)); !equalIDs(got, ids(rs[4:5])) {
+		t.Fatalf("Wrong last page\nexpected: '%v'\ngot:      '%v'", ids(rs[4:5]), got)
+	}
+	if _, ok := linkHeader(resp)["next"]; ok {
+		t.Fatalf("Unexpected next link on last page")
+	}
+
+	resp = req(links["prev"].RawQuery)
+	if got := ids(unmarshalCollection(t, resp)); !equalIDs(got, ids(rs[0:1])) {
+		t.Fatalf("Wrong previous page\nexpected: '%v'\ngot:      '%v'", ids(rs[0:1]), got)
+	}
+	if _, ok := linkHeader(resp)["prev"]; ok {
+		t.Fatalf("Unexpected prev link on first page")
+	}
+}
+
+func createOwnedResources(t *testing.T, n int) (User, []Resource) {
+	u := User{}
+	assertNoErr(db.Save(&u).Error)
+
+	rs := make([]Resource, n)
+	for i := range rs {
+		rs[i] = Resource{UserID: u.ID, Text: "text"}
+		assertNoErr(db.Save(&rs[i]).Error)
+	}
+
+	return u, rs
+}
+
+func mountOwnerHandler(t *testing.T, u *User, handler resources.ModelHandler) func(query string) *httptest.ResponseRecorder {
+	router = gin.New()
+	router.GET("/test", func(ctx *gin.Context) {
+		handler(ctx, u)
+	})
+
+	return func(query string) *httptest.ResponseRecorder {
+		return doRequest(t, "GET", "/test?"+query, nil)
+	}
+}
+
+func unmarshalCollection(t *testing.T, res *httptest.ResponseRecorder) []Resource {
+	b, err := ioutil.ReadAll(res.Body)
+	assertNoErr(err)
+
+	rs := []Resource{}
+	assertNoErr(json.Unmarshal(b, &rs))
+	return rs
+}
+
+var regexpLink = regexp.MustCompile(`<([^>]*)>; rel="(\w+)"`)
+
+func linkHeader(res *httptest.ResponseRecorder) map[string]*url.URL {
+	links := map[string]*url.URL{}
+	for _, m := range regexpLink.FindAllStringSubmatch(res.Header().Get("Link"), -1) {
+		u, err := url.Parse(m[1])
+		assertNoErr(err)
+		links[m[2]] = u
+	}
+	return links
+}
+
+func ids(rs []Resource) []uint {
+	ids := []uint{}
+	for _, r := range rs {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func equalIDs(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,167 @@
+package resources
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"gopkg.in/go-playground/validator.v8"
+)
+
+// partialValidator validates the fields present in a PATCH request,
+// using the same `binding` struct tags as Gin.
+var partialValidator = validator.New(&validator.Config{TagName: "binding"})
+
+// bindPatch applies the JSON object `body` to a copy of `s`, and
+// returns the DB columns (and their new values) of the fields present
+// in `body`. Only the fields present are validated, so `required`
+// fields can be left out, and fields can be set to zero values
+// (`null` sets a field to its zero value).
+//
+// `s` itself isn't changed.
+func bindPatch(db *gorm.DB, body []byte, s DBModel) (map[string]interface{}, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, newValidationError(io.EOF, s)
+	}
+
+	keys := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &keys); err != nil {
+		if _, ok := err.(*json.UnmarshalTypeError); ok {
+			return nil, &ValidationError{Fields: []FieldError{{
+				Rule:    "type",
+				Param:   "object",
+				Message: "must be a JSON object",
+			}}}
+		}
+		return nil, newValidationError(err, s)
+	}
+
+	fields := columnFields(db, s)
+
+	// Present fields by the keys of `body` they are bound from
+	present := map[string]modelField{}
+	unknown := []FieldError{}
+	for key := range keys {
+		f, ok := fieldForKey(fields, key)
+		if !ok {
+			unknown = append(unknown, FieldError{Field: key, Rule: "unknown", Message: "is not a field of this resource"})
+			continue
+		}
+		present[key] = f
+	}
+	if len(unknown) > 0 {
+		sort.Slice(unknown, func(i, j int) bool {
+			return unknown[i].Field < unknown[j].Field
+		})
+		return nil, &ValidationError{Fields: unknown}
+	}
+
+	patched := copyModel(s)
+	for key, f := range present {
+		// Unmarshalling `null` leaves fields unchanged
+		if string(bytes.TrimSpace(keys[key])) == "null" {
+			f.zero(patched)
+		}
+	}
+	if err := json.Unmarshal(body, patched); err != nil {
+		return nil, newValidationError(err, patched)
+	}
+
+	names := []string{}
+	for _, f := range present {
+		names = append(names, strings.Join(f.Names, "."))
+	}
+	if len(names) > 0 {
+		if err := partialValidator.StructPartial(patched, names...); err != nil {
+			return nil, newValidationError(err, patched)
+		}
+	}
+
+	updates := map[string]interface{}{}
+	for _, f := range present {
+		updates[f.DBName] = f.value(patched)
+	}
+	return updates, nil
+}
+
+// patchObject converts a patch document with the given media type into
+// a JSON object of the fields of `s` that the patch changes, as used
+// by bindPatch. The patch is applied to the JSON encoding of `s`, so
+// fields are named by their JSON names. Fields removed by the patch
+// are set to `null`.
+//
+// Documents with media types other than MediaTypeMergePatch and
+// MediaTypeJSONPatch are returned unchanged.
+func patchObject(mediaType string, body []byte, s DBModel) ([]byte, error) {
+	if mediaType != MediaTypeMergePatch && mediaType != MediaTypeJSONPatch {
+		return body, nil
+	}
+
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	original := map[string]interface{}{}
+	if err := decodeJSON(encoded, &original); err != nil {
+		return nil, err
+	}
+
+	// The original is decoded again, as patches change it in place
+	var doc interface{}
+	if err := decodeJSON(encoded, &doc); err != nil {
+		return nil, err
+	}
+
+	switch mediaType {
+	case MediaTypeMergePatch:
+		var patch interface{}
+		if err := decodeJSON(body, &patch); err != nil {
+			return nil, newValidationError(err, s)
+		}
+		if _, ok := patch.(map[string]interface{}); !ok {
+			return nil, &ValidationError{Fields: []FieldError{{
+				Rule:    "type",
+				Param:   "object",
+				Message: "must be a JSON object",
+			}}}
+		}
+		doc = mergePatch(doc, patch)
+	case MediaTypeJSONPatch:
+		ops := []jsonPatchOp{}
+		if err := json.Unmarshal(body, &ops); err != nil {
+			if _, ok := err.(*json.UnmarshalTypeError); ok {
+				return nil, &ValidationError{Fields: []FieldError{{
+					Rule:    "type",
+					Param:   "array",
+					Message: "must be a JSON array of patch operations",
+				}}}
+			}
+			return nil, newValidationError(err, s)
+		}
+		if doc, err = jsonPatch(doc, ops); err != nil {
+			return nil, err
+		}
+	}
+
+	patched, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, &PatchError{Message: "patched resource must be a JSON object"}
+	}
+
+	changed := map[string]interface{}{}
+	for k, v := range patched {
+		if orig, ok := original[k]; !ok || !jsonEqual(orig, v) {
+			changed[k] = v
+		}
+	}
+	for k := range original {
+		if _, ok := patched[k]; !ok {
+			changed[k] = nil
+		}
+	}
+
+	return json.Marshal(changed)
+}
@@ -0,0 +1,121 @@
+package resources
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// Policy decides which actions users can take on the models of a
+// resource. Each method returns nil to allow the action, or an error
+// to respond with instead, usually ErrForbidden (403) or
+// gorm.ErrRecordNotFound (404, to hide that the model exists).
+//
+// The user and parent are those given to the handler, or stored in
+// the request context with SetUser and SetParent for handlers that
+// aren't given them. Either may be nil.
+type Policy interface {
+	// CanList returns a scope that restricts a Collection query to
+	// the models that `user` can list. A nil scope allows every
+	// model.
+	CanList(user User, parent DBModel) (func(*gorm.DB) *gorm.DB, error)
+
+	// CanCreate is called by Post (and Put, when creating) after the
+	// owner and parent of `model` have been set.
+	CanCreate(user User, model DBModel, parent DBModel) error
+
+	// CanRead is called by Get.
+	CanRead(user User, model DBModel, parent DBModel) error
+
+	// CanUpdate is called by Patch and Put with the model as it is
+	// before the update.
+	CanUpdate(user User, model DBModel, parent DBModel) error
+
+	// CanDelete is called by Delete.
+	CanDelete(user User, model DBModel, parent DBModel) error
+}
+
+// FieldPolicy is a Policy that also decides which fields of a
+// resource users can read and write. Fields are named by their JSON
+// names.
+//
+// Fields that a user can't read are left out of responses. Fields
+// that a user can't write are handled like other unwritable fields
+// (see WithUnwritableFields), with the `forbidden` rule.
+type FieldPolicy interface {
+	Policy
+
+	// ReadableFields returns the fields `user` can read, or nil for
+	// every field.
+	ReadableFields(user User) []string
+
+	// WritableFields returns the fields `user` can write, or nil for
+	// every field.
+	WritableFields(user User) []string
+}
+
+const (
+	userKey   = "resources.user"
+	parentKey = "resources.parent"
+)
+
+// SetUser stores the user making a request in the request context,
+// for the Policy checks of handlers that aren't given a User.
+func SetUser(ctx *gin.Context, user User) {
+	ctx.Set(userKey, user)
+}
+
+// GetUser returns the user stored with SetUser, or nil.
+func GetUser(ctx *gin.Context) User {
+	if user, ok := ctx.Get(userKey); ok {
+		if user, ok := user.(User); ok {
+			return user
+		}
+	}
+	return nil
+}
+
+// SetParent stores the parent of the requested model in the request
+// context, for the Policy checks of handlers that aren't given a
+// parent.
+func SetParent(ctx *gin.Context, parent DBModel) {
+	ctx.Set(parentKey, parent)
+}
+
+// GetParent returns the parent stored with SetParent, or nil.
+func GetParent(ctx *gin.Context) DBModel {
+	if parent, ok := ctx.Get(parentKey); ok {
+		if parent, ok := parent.(DBModel); ok {
+			return parent
+		}
+	}
+	return nil
+}
+
+// StoreUser wraps a User provider to store the provided user with
+// SetUser, eg:
+//
+//	Merge(StoreUser(ProvideAuthUser), res.ProvideModel)(res.Patch)
+func StoreUser(p UserProvider) UserProvider {
+	return CurryUserProcessor(func(accepter UserHandler, ctx *gin.Context, user User) {
+		SetUser(ctx, user)
+		accepter(ctx, user)
+	})(p)
+}
+
+// StoreParent wraps a DBModel provider to store the provided model
+// with SetParent.
+func StoreParent(p ModelProvider) ModelProvider {
+	return CurryModelProcessor(func(accepter ModelHandler, ctx *gin.Context, parent DBModel) {
+		SetParent(ctx, parent)
+		accepter(ctx, parent)
+	})(p)
+}
+
+// readableFields returns the fields the user of the request can read,
+// or nil for every field.
+func (h *handlers) readableFields(ctx *gin.Context) []string {
+	if policy, ok := h.policy.(FieldPolicy); ok {
+		return policy.ReadableFields(GetUser(ctx))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package resources_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+
+	"github.com/theplant/resources"
+)
+
+// testPolicy hides "secret" resources from collections, only lets
+// owners update resources, and never lets resources be deleted.
+type testPolicy struct{}
+
+func (testPolicy) CanList(user resources.User, parent resources.DBModel) (func(*gorm.DB) *gorm.DB, error) {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("text <> ?", "secret")
+	}, nil
+}
+
+func (testPolicy) CanCreate(user resources.User, model resources.DBModel, parent resources.DBModel) error {
+	if model.(*Resource).Text == "forbidden" {
+		return resources.ErrForbidden
+	}
+	return nil
+}
+
+func (testPolicy) CanRead(user resources.User, model resources.DBModel, parent resources.DBModel) error {
+	if user == nil {
+		return resources.ErrForbidden
+	}
+	return nil
+}
+
+func (testPolicy) CanUpdate(user resources.User, model resources.DBModel, parent resources.DBModel) error {
+	if user == nil || model.OwnerID() != user.GetID() {
+		return resources.ErrForbidden
+	}
+	return nil
+}
+
+func (testPolicy) CanDelete(user resources.User, model resources.DBModel, parent resources.DBModel) error {
+	return gorm.ErrRecordNotFound
+}
+
+func TestPolicy(t *testing.T) {
+	u, rs := createOwnedResources(t, 3)
+	assertNoErr(db.Model(&rs[0]).Update("text", "secret").Error)
+	other := Resource{Text: "other"}
+	assertNoErr(db.Save(&other).Error)
+
+	policed := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithPolicy(testPolicy{}))
+
+	provideUser := resources.StoreUser(resources.CurryUserProvider(func(handler resources.UserHandler, ctx *gin.Context) {
+		handler(ctx, &u)
+	}))
+	provideParent := resources.StoreParent(resources.UserAsModel(provideUser))
+
+	router = gin.New()
+	router.GET("/r", provideParent(policed.Collection))
+	router.POST("/r", resources.Merge(provideUser, provideParent)(policed.Post))
+	router.GET("/r/:id", resources.Merge(provideUser, policed.ProvideModel)(func(ctx *gin.Context, _ resources.User, m resources.DBModel) {
+		policed.Get(ctx, m)
+	}))
+	router.GET("/anonymous/:id", policed.ProvideModel(policed.Get))
+	router.PATCH("/r/:id", resources.Merge(provideUser, policed.ProvideModel)(func(ctx *gin.Context, _ resources.User, m resources.DBModel) {
+		policed.Patch(ctx, m)
+	}))
+	router.DELETE("/r/:id", resources.Merge(provideUser, policed.ProvideModel)(func(ctx *gin.Context, _ resources.User, m resources.DBModel) {
+		policed.Delete(ctx, m)
+	}))
+
+	resp := doRequest(t, "GET", "/r", nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Error GETting policed collection\nexpected %d, got %d: %v", http.StatusOK, resp.Code, resp)
+	}
+	expected := []uint{rs[1].ID, rs[2].ID}
+	if got := ids(unmarshalCollection(t, resp)); !equalIDs(got, expected) || resp.Header().Get("X-Total-Count") != "2" {
+		t.Fatalf("Policy didn't scope collection:\nexpected: '%v'\ngot:      '%v' (total %s)", expected, got, resp.Header().Get("X-Total-Count"))
+	}
+
+	tests := []struct {
+		Method string
+		Path   string
+		Body   string
+		Code   int
+	}{
+		{"POST", "/r", `{"Text": "forbidden"}`, http.StatusForbidden},
+		{"POST", "/r", `{"Text": "allowed"}`, http.StatusCreated},
+		{"GET", fmt.Sprintf("/r/%d", rs[1].ID), "", http.StatusOK},
+		{"GET", fmt.Sprintf("/anonymous/%d", rs[1].ID), "", http.StatusForbidden},
+		{"PATCH", fmt.Sprintf("/r/%d", rs[1].ID), `{"Text": "updated"}`, http.StatusOK},
+		{"PATCH", fmt.Sprintf("/r/%d", other.ID), `{"Text": "updated"}`, http.StatusForbidden},
+		{"DELETE", fmt.Sprintf("/r/%d", rs[1].ID), "", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		resp := doRequest(t, test.Method, test.Path, strings.NewReader(test.Body))
+		if resp.Code != test.Code {
+			t.Fatalf("Error %s %s with policy\nexpected %d, got %d: %v", test.Method, test.Path, test.Code, resp.Code, resp)
+		}
+	}
+
+	count := 0
+	assertNoErr(db.Model(&Resource{}).Where("text = ?", "forbidden").Count(&count).Error)
+	if count != 0 {
+		t.Fatalf("Resource created despite policy")
+	}
+
+	reloaded := &Resource{}
+	assertNoErr(db.Where("id = ?", other.ID).Find(reloaded).Error)
+	if reloaded.Text != "other" {
+		t.Fatalf("Resource updated despite policy: '%v'", reloaded.Text)
+	}
+}
@@ -0,0 +1,204 @@
+package resources
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// FieldsError is returned for a `fields` parameter that names a field
+// that the resource doesn't have, or that isn't in its view (see
+// WithView).
+type FieldsError struct {
+	Field string
+}
+
+func (err FieldsError) Error() string {
+	return fmt.Sprintf("can't select field %q", err.Field)
+}
+
+// jsonFields returns the fields of `model` that are included in JSON,
+// keyed by their JSON names. Unlike columnFields, associations are
+// included.
+func jsonFields(db *gorm.DB, model interface{}) map[string]*gorm.StructField {
+	fields := map[string]*gorm.StructField{}
+	for _, f := range db.NewScope(model).GetModelStruct().StructFields {
+		if f.IsIgnored {
+			continue
+		}
+
+		if name, ok := jsonFieldName(f.Struct); ok {
+			fields[name] = f
+		}
+	}
+	return fields
+}
+
+// outputFields returns the fields (by JSON name) to include in the
+// response to the request, or nil for every field. These are the
+// fields selected by the `fields` query parameter (eg.
+// `?fields=id,text`, matched case-insensitively), or the resource's
+// view, limited to the fields the user can read.
+func (h *handlers) outputFields(ctx *gin.Context) ([]string, error) {
+	fields := h.viewFields
+	if param := ctx.Request.URL.Query().Get("fields"); param != "" {
+		available := jsonFields(h.db, h.single())
+
+		fields = []string{}
+		for _, requested := range strings.Split(param, ",") {
+			requested = strings.TrimSpace(requested)
+			if requested == "" {
+				continue
+			}
+
+			name := ""
+			for n := range available {
+				if strings.EqualFold(n, requested) {
+					name = n
+					break
+				}
+			}
+			if name == "" || (h.viewFields != nil && !contains(h.viewFields, name)) {
+				return nil, FieldsError{Field: requested}
+			}
+			fields = append(fields, name)
+		}
+	}
+
+	readable := h.readableFields(ctx)
+	if fields == nil || readable == nil {
+		if fields == nil {
+			return readable, nil
+		}
+		return fields, nil
+	}
+
+	both := []string{}
+	for _, f := range fields {
+		if contains(readable, f) {
+			both = append(both, f)
+		}
+	}
+	return both, nil
+}
+
+// selectColumns returns a scope that only selects the columns needed
+// for `fields` from the table of `scope`, along with the primary key
+// and `updated_at` column that pagination and caching need, the ID
+// column that links need (see WithIDCodec), and the foreign keys that
+// preloading needs. Every column is selected if `fields` is nil.
+func (h *handlers) selectColumns(scope *gorm.Scope, fields []string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if fields == nil {
+			return db
+		}
+
+		all := jsonFields(h.db, h.single())
+
+		needed := []string{h.idColumn()}
+		for _, f := range all {
+			if f.Relationship != nil && f.Relationship.Kind == "belongs_to" {
+				needed = append(needed, f.Relationship.ForeignDBNames...)
+			}
+		}
+
+		columns := []string{}
+		for name, f := range all {
+			if !f.IsNormal || !(f.IsPrimaryKey || f.DBName == updatedAtColumn || contains(needed, f.DBName) || contains(fields, name)) {
+				continue
+			}
+			columns = append(columns, fmt.Sprintf("%s.%s", scope.QuotedTableName(), scope.Quote(f.DBName)))
+		}
+		return db.Select(columns)
+	}
+}
+
+// checkOutput checks that the response to the request can be
+// rendered, before any changes are made: that its fields are valid
+// and that its media type is acceptable.
+func (h *handlers) checkOutput(ctx *gin.Context) error {
+	if _, err := h.outputFields(ctx); err != nil {
+		return err
+	}
+	_, err := h.serializer(ctx)
+	return err
+}
+
+// document prepares `obj`, a model, to be serialized with only
+// `fields` (or every field if `fields` is nil).
+func (h *handlers) document(ctx *gin.Context, obj interface{}, fields []string) (Document, error) {
+	doc := Document{Object: obj}
+	if fields != nil {
+		view, err := selectFields(obj, fields)
+		if err != nil {
+			return doc, err
+		}
+		doc.Object = view
+	}
+
+	doc.Type = h.db.NewScope(obj).TableName()
+	doc.IDField = columnJSONName(h.db, obj, h.idColumn())
+
+	if s, ok := obj.(DBModel); ok {
+		var err error
+		if doc.ID, err = h.formatID(s); err != nil {
+			return doc, err
+		}
+		if doc.Link, err = h.link(ctx, s); err != nil {
+			return doc, err
+		}
+	}
+	return doc, nil
+}
+
+// render renders `s` with the serializer for the request, returning
+// the content type and body of the response.
+func (h *handlers) render(ctx *gin.Context, s DBModel) (string, []byte, error) {
+	serializer, err := h.serializer(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	fields, err := h.outputFields(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	doc, err := h.document(ctx, s, fields)
+	if err != nil {
+		return "", nil, err
+	}
+
+	body, err := serializer.Single(doc)
+	if err != nil {
+		return "", nil, err
+	}
+	return serializer.ContentType(), body, nil
+}
+
+// respond renders `s` with the serializer for the request.
+func (h *handlers) respond(ctx *gin.Context, status int, s DBModel) {
+	contentType, body, err := h.render(ctx, s)
+	if err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+	ctx.Data(status, contentType, body)
+}
+
+// respondTagged renders `s` like respond, with the `ETag` header of
+// the representation (see representationETag).
+func (h *handlers) respondTagged(ctx *gin.Context, status int, s DBModel) {
+	contentType, body, err := h.render(ctx, s)
+	if err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	if etag := h.representationETag(s, contentType, body); etag != "" {
+		ctx.Header("ETag", etag)
+	}
+	ctx.Data(status, contentType, body)
+}
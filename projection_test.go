@@ -0,0 +1,82 @@
+package resources_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theplant/resources"
+)
+
+func TestSparseFieldsets(t *testing.T) {
+	u, rs := createOwnedResources(t, 3)
+
+	viewed := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithView("ID", "Text", "UserID"))
+
+	tests := []struct {
+		Resource resources.Resource
+		Method   string
+		Path     string
+		Body     string
+		Code     int
+		Fields   string
+	}{
+		{res, "GET", fmt.Sprintf("/r/%d?fields=id,text", rs[0].ID), "", http.StatusOK, "ID,Text"},
+		{res, "GET", fmt.Sprintf("/r/%d?fields=User", rs[0].ID), "", http.StatusOK, "User"},
+		{res, "GET", fmt.Sprintf("/r/%d?fields=id,unknown", rs[0].ID), "", http.StatusBadRequest, ""},
+		{res, "GET", "/r?fields=text&limit=2", "", http.StatusOK, "Text"},
+		{res, "PATCH", fmt.Sprintf("/r/%d?fields=Count", rs[0].ID), `{"Count": 3}`, http.StatusOK, "Count"},
+		{res, "PATCH", fmt.Sprintf("/r/%d?fields=unknown", rs[0].ID), `{"Count": 4}`, http.StatusBadRequest, ""},
+		{viewed, "GET", fmt.Sprintf("/r/%d", rs[0].ID), "", http.StatusOK, "ID,Text,UserID"},
+		{viewed, "GET", fmt.Sprintf("/r/%d?fields=text", rs[0].ID), "", http.StatusOK, "Text"},
+		{viewed, "GET", fmt.Sprintf("/r/%d?fields=count", rs[0].ID), "", http.StatusBadRequest, ""},
+		{viewed, "GET", "/r", "", http.StatusOK, "ID,Text,UserID"},
+	}
+
+	for _, test := range tests {
+		r := test.Resource
+		router = gin.New()
+		router.GET("/r", func(ctx *gin.Context) {
+			r.Collection(ctx, &u)
+		})
+		router.GET("/r/:id", r.ProvideModel(r.Get))
+		router.PATCH("/r/:id", r.ProvideModel(r.Patch))
+
+		resp := doRequest(t, test.Method, test.Path, strings.NewReader(test.Body))
+		if resp.Code != test.Code {
+			t.Fatalf("Error %s %s\nexpected %d, got %d: %v", test.Method, test.Path, test.Code, resp.Code, resp)
+		}
+
+		if test.Fields != "" {
+			if fields := strings.Join(responseFields(t, resp), ","); fields != test.Fields {
+				t.Fatalf("Wrong fields in response to %s %s:\nexpected: '%v'\ngot:      '%v'", test.Method, test.Path, test.Fields, fields)
+			}
+		}
+	}
+
+	reloaded := &Resource{}
+	assertNoErr(db.Where("id = ?", rs[0].ID).Find(reloaded).Error)
+	if reloaded.Count != 3 {
+		t.Fatalf("Wrong result of PATCH with fields: %v", reloaded.Count)
+	}
+
+	// Pagination still works with only some columns selected
+	router = gin.New()
+	router.GET("/r", func(ctx *gin.Context) {
+		res.Collection(ctx, &u)
+	})
+	resp := doRequest(t, "GET", "/r?fields=text&limit=2&cursor=", nil)
+	next, ok := linkHeader(resp)["next"]
+	if !ok {
+		t.Fatalf("Missing next link with fields: %v", resp)
+	}
+	resp = doRequest(t, "GET", next.String(), nil)
+	if body := strings.TrimSpace(resp.Body.String()); resp.Code != http.StatusOK || body != `[{"Text":"text"}]` {
+		t.Fatalf("Wrong next page with fields: %v", resp)
+	}
+}
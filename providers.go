@@ -1,6 +1,12 @@
 package resources
 
-import "github.com/gin-gonic/gin"
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
 
 // UserProvider is a function that knows how to "find" (or "provide")
 // a User, given a request context. It doesn't do anything with the
@@ -128,3 +134,43 @@ func CurryUserModelProcessor(fn func(UserModelHandler, *gin.Context, User, DBMod
 		}
 	}
 }
+
+// RequireOwner wraps a User + DBModel provider so that the handler is
+// only called when the user owns the model, ie. when
+// `model.OwnerID()` is `user.GetID()`. Otherwise it responds with 404,
+// so that the existence of other users' models isn't leaked.
+//
+// It composes with the other providers and processors, eg:
+//
+//	RequireOwner(Merge(ProvideAuthUser, res.ProvideModel))(res.Patch)
+func RequireOwner(p UserModelProvider) UserModelProvider {
+	return RequireOwnerWithStatus(http.StatusNotFound)(p)
+}
+
+// RequireOwnerWithStatus is like RequireOwner, but responds with
+// `status` when the user doesn't own the model: either
+// `http.StatusNotFound` or `http.StatusForbidden`. It panics for any
+// other status.
+//
+// Responses are made by the resource that provided the model (see
+// ProvideModel), as its handlers would respond to gorm.ErrRecordNotFound
+// or ErrForbidden.
+func RequireOwnerWithStatus(status int) func(UserModelProvider) UserModelProvider {
+	var err error
+	switch status {
+	case http.StatusNotFound:
+		err = gorm.ErrRecordNotFound
+	case http.StatusForbidden:
+		err = ErrForbidden
+	default:
+		panic(fmt.Sprintf("resources: RequireOwnerWithStatus can't respond with %d", status))
+	}
+
+	return CurryUserModelProcessor(func(accepter UserModelHandler, ctx *gin.Context, user User, model DBModel) {
+		if user == nil || model == nil || model.OwnerID() != user.GetID() {
+			abortWithError(ctx, err)
+			return
+		}
+		accepter(ctx, user, model)
+	})
+}
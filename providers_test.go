@@ -2,6 +2,8 @@ package resources_test
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -44,6 +46,93 @@ func TestMergeWithCurry(t *testing.T) {
 	}
 }
 
+func TestRequireOwner(t *testing.T) {
+	user := &User{gorm.Model{ID: 1}}
+	owned := &Resource{Model: gorm.Model{ID: 2}, UserID: user.ID}
+	other := &Resource{Model: gorm.Model{ID: 3}, UserID: user.ID + 1}
+
+	var model resources.DBModel
+	provider := resources.Merge(
+		resources.CurryUserProvider(func(handler resources.UserHandler, ctx *gin.Context) {
+			handler(ctx, user)
+		}),
+		resources.CurryModelProvider(func(handler resources.ModelHandler, ctx *gin.Context) {
+			handler(ctx, model)
+		}),
+	)
+
+	handler := func(ctx *gin.Context, u resources.User, m resources.DBModel) {
+		ctx.String(http.StatusOK, "OK")
+	}
+
+	tests := []struct {
+		Provider resources.UserModelProvider
+		Model    resources.DBModel
+		Code     int
+	}{
+		{resources.RequireOwner(provider), owned, http.StatusOK},
+		{resources.RequireOwner(provider), other, http.StatusNotFound},
+		{resources.RequireOwnerWithStatus(http.StatusForbidden)(provider), owned, http.StatusOK},
+		{resources.RequireOwnerWithStatus(http.StatusForbidden)(provider), other, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		model = test.Model
+
+		router = gin.New()
+		router.GET("/test", test.Provider(handler))
+		res := doRequest(t, "GET", "/test", nil)
+
+		if res.Code != test.Code {
+			t.Fatalf("Error requiring owner of %v\nexpected %d, got %d: %v", model, test.Code, res.Code, res)
+		}
+	}
+}
+
+func TestRequireOwnerResponse(t *testing.T) {
+	_, rs := createOwnedResources(t, 1)
+	other := User{}
+	assertNoErr(db.Save(&other).Error)
+
+	sinked := []error{}
+	serialized := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithSerializers(resources.JSONSerializer{}, resources.JSONAPISerializer{}),
+		resources.WithErrorSink(func(ctx *gin.Context, err error) {
+			sinked = append(sinked, err)
+		}))
+
+	provider := resources.Merge(
+		resources.CurryUserProvider(func(handler resources.UserHandler, ctx *gin.Context) {
+			handler(ctx, &other)
+		}),
+		serialized.ProvideModel,
+	)
+
+	router = gin.New()
+	router.GET("/r/:id", resources.RequireOwnerWithStatus(http.StatusForbidden)(provider)(func(ctx *gin.Context, u resources.User, m resources.DBModel) {
+		ctx.String(http.StatusOK, "OK")
+	}))
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("/r/%d", rs[0].ID), nil)
+	assertNoErr(err)
+	req.Header.Set("Accept", "application/vnd.api+json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	// The resource that provided the model responds
+	if resp.Code != http.StatusForbidden || resp.Header().Get("Content-Type") != "application/vnd.api+json" || len(sinked) != 1 {
+		t.Fatalf("Wrong response to non-owner\nexpected %d from resource, got %d: %v", http.StatusForbidden, resp.Code, resp)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("RequireOwnerWithStatus didn't panic with status %d", http.StatusUnauthorized)
+		}
+	}()
+	resources.RequireOwnerWithStatus(http.StatusUnauthorized)
+}
+
 func exampleProvider() {
 
 	CurriedPreProcessModelUser := resources.CurryUserModelProcessor(func(accepter resources.UserModelHandler, ctx *gin.Context, user resources.User, model resources.DBModel) {
@@ -0,0 +1,167 @@
+package resources_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theplant/resources"
+)
+
+func TestPut(t *testing.T) {
+	u := User{}
+	assertNoErr(db.Save(&u).Error)
+	other := User{}
+	assertNoErr(db.Save(&other).Error)
+
+	r := Resource{Text: "original", Count: 5, UserID: u.ID}
+	assertNoErr(db.Save(&r).Error)
+	otherR := Resource{Text: "other", UserID: other.ID}
+	assertNoErr(db.Save(&otherR).Error)
+
+	req := mountPutHandler(t, &u, res.Put)
+
+	tests := []struct {
+		ID    uint
+		Body  string
+		Code  int
+		Text  string
+		Count int
+	}{
+		// Fields left out of the body are zeroed
+		{r.ID, `{"Text": "replaced"}`, http.StatusOK, "replaced", 0},
+		{r.ID, `{"Text": "counted", "Count": 3}`, http.StatusOK, "counted", 3},
+		// The body can't change the ID or owner
+		{r.ID, fmt.Sprintf(`{"UserID": %d, "Text": "moved", "Count": 3}`, other.ID), resources.HTTPStatusUnprocessableEntity, "counted", 3},
+		{r.ID, fmt.Sprintf(`{"ID": %d, "Text": "renumbered", "Count": 3}`, otherR.ID), resources.HTTPStatusUnprocessableEntity, "counted", 3},
+		{r.ID, `{"Count": 4}`, resources.HTTPStatusUnprocessableEntity, "counted", 3},
+		{r.ID, `{"Text": "too many", "Count": 11}`, resources.HTTPStatusUnprocessableEntity, "counted", 3},
+		{otherR.ID, `{"Text": "stolen"}`, http.StatusNotFound, "counted", 3},
+		{otherR.ID + 100, `{"Text": "missing"}`, http.StatusNotFound, "counted", 3},
+	}
+
+	for _, test := range tests {
+		resp := req(test.ID, test.Body)
+		if resp.Code != test.Code {
+			t.Fatalf("Error PUTting %s to %d\nexpected %d, got %d: %v", test.Body, test.ID, test.Code, resp.Code, resp)
+		}
+
+		reloaded := &Resource{}
+		assertNoErr(db.Where("id = ?", r.ID).Find(reloaded).Error)
+
+		if reloaded.Text != test.Text || reloaded.Count != test.Count || reloaded.UserID != u.ID {
+			t.Fatalf("Wrong result PUTting %s\nexpected: '%v', '%v', '%v'\ngot:      '%v', '%v', '%v'", test.Body, test.Text, test.Count, u.ID, reloaded.Text, reloaded.Count, reloaded.UserID)
+		}
+	}
+
+	reloaded := &Resource{}
+	assertNoErr(db.Where("id = ?", otherR.ID).Find(reloaded).Error)
+	if reloaded.Text != "other" || reloaded.UserID != other.ID {
+		t.Fatalf("PUT changed another user's resource: %v", reloaded)
+	}
+}
+
+func TestPutRoundTrip(t *testing.T) {
+	u := User{}
+	assertNoErr(db.Save(&u).Error)
+	r := Resource{Text: "original", Count: 5, UserID: u.ID}
+	assertNoErr(db.Save(&r).Error)
+
+	req := mountPutHandler(t, &u, res.Put)
+	router.GET("/r/:id", res.ProvideModel(res.Get))
+
+	resp := doRequest(t, "GET", fmt.Sprintf("/r/%d", r.ID), nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Error GETting resource\nexpected %d, got %d: %v", http.StatusOK, resp.Code, resp)
+	}
+
+	// The representation from Get can be changed and PUT back
+	representation := map[string]interface{}{}
+	assertNoErr(json.Unmarshal(resp.Body.Bytes(), &representation))
+	representation["Text"] = "round trip"
+	b, err := json.Marshal(representation)
+	assertNoErr(err)
+
+	if resp := req(r.ID, string(b)); resp.Code != http.StatusOK {
+		t.Fatalf("Error PUTting representation %s\nexpected %d, got %d: %v", b, http.StatusOK, resp.Code, resp)
+	}
+
+	// ...but not with changes to fields that can't be written
+	representation["CreatedAt"] = "2000-01-01T00:00:00Z"
+	b, err = json.Marshal(representation)
+	assertNoErr(err)
+
+	if resp := req(r.ID, string(b)); resp.Code != resources.HTTPStatusUnprocessableEntity {
+		t.Fatalf("Error PUTting changed timestamp %s\nexpected %d, got %d: %v", b, resources.HTTPStatusUnprocessableEntity, resp.Code, resp)
+	}
+
+	reloaded := &Resource{}
+	assertNoErr(db.Where("id = ?", r.ID).Find(reloaded).Error)
+	if reloaded.Text != "round trip" || reloaded.Count != 5 || reloaded.UserID != u.ID {
+		t.Fatalf("Wrong result PUTting representation: %v", reloaded)
+	}
+}
+
+func TestPutCreate(t *testing.T) {
+	u := User{}
+	assertNoErr(db.Save(&u).Error)
+
+	last := Resource{}
+	assertNoErr(db.Save(&last).Error)
+	id := last.ID + 1000
+
+	req := mountPutHandler(t, &u, res.Put)
+	if resp := req(id, `{"Text": "created"}`); resp.Code != http.StatusNotFound {
+		t.Fatalf("Error PUTting new resource without WithCreateOnPut\nexpected %d, got %d: %v", http.StatusNotFound, resp.Code, resp)
+	}
+
+	creating := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithLinker(func(id uint) string { return fmt.Sprintf("/r/%d", id) }),
+		resources.WithCreateOnPut())
+
+	req = mountPutHandler(t, &u, creating.Put)
+	resp := req(id, `{"Text": "created"}`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Error PUTting new resource\nexpected %d, got %d: %v", http.StatusCreated, resp.Code, resp)
+	}
+
+	expectedLocation := fmt.Sprintf("/r/%d", id)
+	if location := resp.Header().Get("Location"); location != expectedLocation {
+		t.Fatalf("Wrong Location header:\nexpected: '%v'\ngot:      '%v'", expectedLocation, location)
+	}
+
+	created := &Resource{}
+	assertNoErr(db.Where("id = ?", id).Find(created).Error)
+	if created.Text != "created" || created.UserID != u.ID {
+		t.Fatalf("Wrong resource created by PUT: %v", created)
+	}
+
+	// The ID of a soft-deleted resource can't be reused
+	assertNoErr(db.Delete(created).Error)
+	if resp := req(id, `{"Text": "recreated"}`); resp.Code != http.StatusNotFound {
+		t.Fatalf("Error PUTting soft-deleted resource\nexpected %d, got %d: %v", http.StatusNotFound, resp.Code, resp)
+	}
+
+	deleted := &Resource{}
+	assertNoErr(db.Unscoped().Where("id = ?", id).Find(deleted).Error)
+	if deleted.Text != "created" || deleted.DeletedAt == nil {
+		t.Fatalf("PUT changed soft-deleted resource: %v", deleted)
+	}
+}
+
+func mountPutHandler(t *testing.T, u *User, handler resources.UserModelHandler) func(id uint, body string) *httptest.ResponseRecorder {
+	router = gin.New()
+	router.PUT("/r/:id", func(ctx *gin.Context) {
+		handler(ctx, u, u)
+	})
+
+	return func(id uint, body string) *httptest.ResponseRecorder {
+		return doRequest(t, "PUT", fmt.Sprintf("/r/%d", id), strings.NewReader(body))
+	}
+}
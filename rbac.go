@@ -0,0 +1,201 @@
+package resources
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// RoleUser is a User with roles, as used by RBAC. Users that don't
+// implement RoleUser have no roles.
+type RoleUser interface {
+	User
+
+	Roles() []string
+}
+
+// Action is something a user can do to a resource.
+type Action string
+
+// Actions of the Resource handlers.
+const (
+	ActionList   Action = "list"
+	ActionCreate Action = "create"
+	ActionRead   Action = "read"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// Role is what the users with a role can do to a resource.
+type Role struct {
+	// Actions the role allows
+	Actions []Action
+
+	// AllOwners allows the actions on every owner's models. Otherwise
+	// they are only allowed on models owned by the user.
+	AllOwners bool
+
+	// ReadFields are the JSON names of the fields the role can read,
+	// or nil for every field.
+	ReadFields []string
+
+	// WriteFields are the JSON names of the fields the role can
+	// write, or nil for every field.
+	WriteFields []string
+}
+
+// RBAC is a Policy (and FieldPolicy) that allows the actions of the
+// roles of a RoleUser, eg:
+//
+//	RBAC{
+//		OwnerColumn: "user_id",
+//		Roles: map[string]Role{
+//			"admin": {Actions: []Action{ActionList, ActionRead, ActionUpdate}, AllOwners: true},
+//			"user":  {Actions: []Action{ActionList, ActionRead}, ReadFields: []string{"ID", "Text"}},
+//		},
+//	}
+//
+// Actions that no role allows are refused with ErrForbidden. Actions
+// on models owned by other users are refused with
+// gorm.ErrRecordNotFound, so that their existence isn't leaked, unless
+// a role allowing the action has AllOwners.
+type RBAC struct {
+	Roles map[string]Role
+
+	// OwnerColumn is the DB column of the owner ID of models, used to
+	// restrict collections to the user's own models. Collections
+	// aren't restricted if it is empty.
+	OwnerColumn string
+}
+
+// permission returns whether one of the roles of `user` allows
+// `action`, and whether it is allowed on every owner's models.
+func (rbac RBAC) permission(user User, action Action) (allowed bool, allOwners bool) {
+	for _, role := range rbac.roles(user) {
+		for _, a := range role.Actions {
+			if a == action {
+				allowed = true
+				allOwners = allOwners || role.AllOwners
+			}
+		}
+	}
+	return allowed, allOwners
+}
+
+func (rbac RBAC) roles(user User) []Role {
+	u, ok := user.(RoleUser)
+	if !ok {
+		return nil
+	}
+
+	roles := []Role{}
+	for _, name := range u.Roles() {
+		if role, ok := rbac.Roles[name]; ok {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// allows returns nil if `user` can take `action` on `model`.
+func (rbac RBAC) allows(user User, action Action, model DBModel) error {
+	allowed, allOwners := rbac.permission(user, action)
+	if !allowed {
+		return ErrForbidden
+	}
+	if !allOwners && model != nil && model.OwnerID() != user.GetID() {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// fields returns the union of the fields of the roles of `user` that
+// allow one of `actions`, or nil if one of the roles allows every
+// field.
+func (rbac RBAC) fields(user User, fields func(Role) []string, actions ...Action) []string {
+	union := []string{}
+	for _, role := range rbac.roles(user) {
+		allowed := false
+		for _, a := range role.Actions {
+			for _, action := range actions {
+				allowed = allowed || a == action
+			}
+		}
+		if !allowed {
+			continue
+		}
+
+		if fields(role) == nil {
+			return nil
+		}
+		union = append(union, fields(role)...)
+	}
+	return union
+}
+
+// CanList allows listing if a role of the user allows ActionList. If
+// none of those roles has AllOwners, the collection is restricted to
+// the user's own models by the OwnerColumn.
+func (rbac RBAC) CanList(user User, parent DBModel) (func(*gorm.DB) *gorm.DB, error) {
+	allowed, allOwners := rbac.permission(user, ActionList)
+	if !allowed {
+		return nil, ErrForbidden
+	}
+	if allOwners || rbac.OwnerColumn == "" {
+		return nil, nil
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(db.Dialect().Quote(rbac.OwnerColumn)+" = ?", user.GetID())
+	}, nil
+}
+
+// CanCreate is part of Policy.
+func (rbac RBAC) CanCreate(user User, model DBModel, parent DBModel) error {
+	return rbac.allows(user, ActionCreate, model)
+}
+
+// CanRead is part of Policy.
+func (rbac RBAC) CanRead(user User, model DBModel, parent DBModel) error {
+	return rbac.allows(user, ActionRead, model)
+}
+
+// CanUpdate is part of Policy.
+func (rbac RBAC) CanUpdate(user User, model DBModel, parent DBModel) error {
+	return rbac.allows(user, ActionUpdate, model)
+}
+
+// CanDelete is part of Policy.
+func (rbac RBAC) CanDelete(user User, model DBModel, parent DBModel) error {
+	return rbac.allows(user, ActionDelete, model)
+}
+
+// ReadableFields is part of FieldPolicy.
+func (rbac RBAC) ReadableFields(user User) []string {
+	return rbac.fields(user, func(r Role) []string { return r.ReadFields }, ActionList, ActionRead)
+}
+
+// WritableFields is part of FieldPolicy.
+func (rbac RBAC) WritableFields(user User) []string {
+	return rbac.fields(user, func(r Role) []string { return r.WriteFields }, ActionCreate, ActionUpdate)
+}
+
+// Require wraps a User + DBModel provider so that the handler is only
+// called if the user can take `action` on the model. The user is
+// stored with SetUser, so that the handler can check fields too.
+// Denials are responded to by the resource that provided the model
+// (see ProvideModel), as its handlers would. For example:
+//
+//	rbac.Require(ActionUpdate)(Merge(ProvideAuthUser, res.ProvideModel))
+func (rbac RBAC) Require(action Action) func(UserModelProvider) UserModelProvider {
+	return CurryUserModelProcessor(func(accepter UserModelHandler, ctx *gin.Context, user User, model DBModel) {
+		SetUser(ctx, user)
+
+		err := rbac.allows(user, action, model)
+		if err == nil {
+			accepter(ctx, user, model)
+			return
+		}
+
+		abortWithError(ctx, err)
+	})
+}
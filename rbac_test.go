@@ -0,0 +1,162 @@
+package resources_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theplant/resources"
+)
+
+type roleUser struct {
+	*User
+
+	roles []string
+}
+
+func (u roleUser) Roles() []string {
+	return u.roles
+}
+
+var testRBAC = resources.RBAC{
+	OwnerColumn: "user_id",
+	Roles: map[string]resources.Role{
+		"admin": {
+			Actions:   []resources.Action{resources.ActionList, resources.ActionRead, resources.ActionUpdate, resources.ActionDelete},
+			AllOwners: true,
+		},
+		"user": {
+			Actions:     []resources.Action{resources.ActionList, resources.ActionRead, resources.ActionUpdate},
+			ReadFields:  []string{"ID", "Text", "UserID"},
+			WriteFields: []string{"Text"},
+		},
+	},
+}
+
+func TestRBAC(t *testing.T) {
+	u, rs := createOwnedResources(t, 2)
+	other, otherRs := createOwnedResources(t, 1)
+	admin := User{}
+	assertNoErr(db.Save(&admin).Error)
+
+	users := map[string]roleUser{
+		"admin": {&admin, []string{"admin"}},
+		"user":  {&u, []string{"user"}},
+	}
+
+	rbaced := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithPolicy(testRBAC))
+
+	provideUser := resources.CurryUserProvider(func(handler resources.UserHandler, ctx *gin.Context) {
+		handler(ctx, users[ctx.Request.Header.Get("X-User")])
+	})
+	provideOwner := resources.CurryModelProvider(func(handler resources.ModelHandler, ctx *gin.Context) {
+		owner := &User{}
+		assertNoErr(db.Where("id = ?", ctx.Param("owner")).Find(owner).Error)
+		handler(ctx, owner)
+	})
+	withModel := func(action resources.Action, handler resources.ModelHandler) gin.HandlerFunc {
+		return testRBAC.Require(action)(resources.Merge(provideUser, rbaced.ProvideModel))(func(ctx *gin.Context, _ resources.User, m resources.DBModel) {
+			handler(ctx, m)
+		})
+	}
+
+	router = gin.New()
+	router.GET("/users/:owner/r", resources.DiscardUser(resources.Merge(resources.StoreUser(provideUser), provideOwner))(rbaced.Collection))
+	router.GET("/r/:id", withModel(resources.ActionRead, rbaced.Get))
+	router.PATCH("/r/:id", withModel(resources.ActionUpdate, rbaced.Patch))
+	router.DELETE("/r/:id", withModel(resources.ActionDelete, rbaced.Delete))
+
+	tests := []struct {
+		User   string
+		Method string
+		Path   string
+		Body   string
+		Code   int
+		// Fields in the response body
+		Fields []string
+	}{
+		{"user", "GET", fmt.Sprintf("/users/%d/r", u.ID), "", http.StatusOK, []string{"ID", "Text", "UserID"}},
+		{"admin", "GET", fmt.Sprintf("/users/%d/r", other.ID), "", http.StatusOK, nil},
+		{"user", "GET", fmt.Sprintf("/r/%d", rs[0].ID), "", http.StatusOK, []string{"ID", "Text", "UserID"}},
+		{"user", "GET", fmt.Sprintf("/r/%d", otherRs[0].ID), "", http.StatusNotFound, nil},
+		{"admin", "GET", fmt.Sprintf("/r/%d", otherRs[0].ID), "", http.StatusOK, nil},
+		{"user", "PATCH", fmt.Sprintf("/r/%d", rs[0].ID), `{"Count": 1}`, resources.HTTPStatusUnprocessableEntity, nil},
+		{"user", "PATCH", fmt.Sprintf("/r/%d", rs[0].ID), `{"Text": "mine"}`, http.StatusOK, []string{"ID", "Text", "UserID"}},
+		{"user", "PATCH", fmt.Sprintf("/r/%d", otherRs[0].ID), `{"Text": "theirs"}`, http.StatusNotFound, nil},
+		{"admin", "PATCH", fmt.Sprintf("/r/%d", otherRs[0].ID), `{"Count": 2}`, http.StatusOK, nil},
+		{"user", "DELETE", fmt.Sprintf("/r/%d", rs[1].ID), "", http.StatusForbidden, nil},
+		{"admin", "DELETE", fmt.Sprintf("/r/%d", rs[1].ID), "", http.StatusNoContent, nil},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(test.Method, test.Path, strings.NewReader(test.Body))
+		assertNoErr(err)
+		req.Header.Set("X-User", test.User)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != test.Code {
+			t.Fatalf("Error %s %s as %s\nexpected %d, got %d: %v", test.Method, test.Path, test.User, test.Code, resp.Code, resp)
+		}
+
+		if test.Fields != nil {
+			if fields := responseFields(t, resp); strings.Join(fields, ",") != strings.Join(test.Fields, ",") {
+				t.Fatalf("Wrong fields in response to %s %s as %s:\nexpected: '%v'\ngot:      '%v'", test.Method, test.Path, test.User, test.Fields, fields)
+			}
+		}
+	}
+
+	// Normal users can't see other owners' resources
+	req, err := http.NewRequest("GET", fmt.Sprintf("/users/%d/r", other.ID), nil)
+	assertNoErr(err)
+	req.Header.Set("X-User", "user")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK || resp.Header().Get("X-Total-Count") != "0" {
+		t.Fatalf("User listed other owner's resources: %v", resp)
+	}
+
+	// Denials are responded to like the resource's other errors
+	req, err = http.NewRequest("DELETE", fmt.Sprintf("/r/%d", rs[0].ID), nil)
+	assertNoErr(err)
+	req.Header.Set("X-User", "user")
+	req.Header.Set(resources.RequestIDHeader, "denied")
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	var body map[string]interface{}
+	assertNoErr(json.Unmarshal(resp.Body.Bytes(), &body))
+	if resp.Code != http.StatusForbidden || body["request_id"] != "denied" {
+		t.Fatalf("Wrong response to denied request: %v", resp)
+	}
+
+	reloaded := &Resource{}
+	assertNoErr(db.Where("id = ?", otherRs[0].ID).Find(reloaded).Error)
+	if reloaded.Text != "text" || reloaded.Count != 2 {
+		t.Fatalf("Wrong result of RBAC PATCHes: '%v', '%v'", reloaded.Text, reloaded.Count)
+	}
+}
+
+// responseFields returns the sorted keys of the JSON object (or first
+// object of the JSON array) in the response body.
+func responseFields(t *testing.T, resp *httptest.ResponseRecorder) []string {
+	var v interface{}
+	assertNoErr(json.Unmarshal(resp.Body.Bytes(), &v))
+	if items, ok := v.([]interface{}); ok && len(items) > 0 {
+		v = items[0]
+	}
+
+	fields := []string{}
+	for k := range v.(map[string]interface{}) {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	return fields
+}
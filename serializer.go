@@ -0,0 +1,360 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrNotAcceptable is returned when none of a resource's serializers
+// can render a media type accepted by the request. Handlers respond
+// to it with 406.
+var ErrNotAcceptable = errors.New("not acceptable")
+
+// Serializer renders the bodies of responses in one media type. The
+// serializer used for a request is chosen by its `Accept` header
+// (see WithSerializers).
+type Serializer interface {
+	// ContentType is the `Content-Type` header of the bodies the
+	// serializer renders, eg. `application/json; charset=utf-8`.
+	ContentType() string
+
+	// Single renders a single model.
+	Single(doc Document) ([]byte, error)
+
+	// Collection renders a page of a collection, with the links to
+	// the pages either side of it, if any.
+	Collection(docs []Document, links []Link) ([]byte, error)
+
+	// Error renders an error response, with the status and body
+	// returned by the resource's ErrorMapper.
+	Error(status int, body interface{}) ([]byte, error)
+
+	// MultiStatus renders the results of the items of a bulk request
+	// in BulkPerItem mode, whose bodies were rendered by the
+	// serializer.
+	MultiStatus(results []BulkResult) ([]byte, error)
+}
+
+// Document is a model ready to be serialized.
+type Document struct {
+	// Type is the type of the model: its table name.
+	Type string
+
+	// ID is the ID of the model, as formatted by the resource's
+	// IDCodec.
+	ID string
+
+	// IDField is the JSON name of the ID field.
+	IDField string
+
+	// Link is the URL of the model from the resource's linker, or ""
+	// if the resource has no linker.
+	Link string
+
+	// Object is the model, or a map of the fields selected by the
+	// request, to be encoded as JSON.
+	Object interface{}
+}
+
+// fields returns the fields of the document's object by JSON name.
+func (doc Document) fields() (map[string]interface{}, error) {
+	encoded, err := json.Marshal(doc.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	if err := decodeJSON(encoded, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// Link is a link from a response to a related URL, eg. the next page
+// of a collection.
+type Link struct {
+	Rel  string
+	Href string
+}
+
+// JSONSerializer renders models as plain JSON objects and collections
+// as JSON arrays of them. It is the default serializer.
+type JSONSerializer struct{}
+
+// ContentType is `application/json`.
+func (JSONSerializer) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+// Single renders the document's object.
+func (JSONSerializer) Single(doc Document) ([]byte, error) {
+	return json.Marshal(doc.Object)
+}
+
+// Collection renders an array of the documents' objects. Links are
+// only given in the `Link` header.
+func (JSONSerializer) Collection(docs []Document, links []Link) ([]byte, error) {
+	objects := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		objects[i] = doc.Object
+	}
+	return json.Marshal(objects)
+}
+
+// Error renders the body as it is.
+func (JSONSerializer) Error(status int, body interface{}) ([]byte, error) {
+	return json.Marshal(body)
+}
+
+// MultiStatus renders an array of the results.
+func (JSONSerializer) MultiStatus(results []BulkResult) ([]byte, error) {
+	return json.Marshal(results)
+}
+
+// JSONAPISerializer renders documents as described by the JSON:API
+// specification (http://jsonapi.org), with every field other than the
+// ID as an attribute.
+type JSONAPISerializer struct{}
+
+// ContentType is `application/vnd.api+json`, which JSON:API doesn't
+// allow parameters on.
+func (JSONAPISerializer) ContentType() string {
+	return "application/vnd.api+json"
+}
+
+func (JSONAPISerializer) resource(doc Document) (gin.H, error) {
+	attributes, err := doc.fields()
+	if err != nil {
+		return nil, err
+	}
+	delete(attributes, doc.IDField)
+
+	resource := gin.H{"type": doc.Type, "id": doc.ID, "attributes": attributes}
+	if doc.Link != "" {
+		resource["links"] = gin.H{"self": doc.Link}
+	}
+	return resource, nil
+}
+
+// Single renders a document with the model as its primary data.
+func (s JSONAPISerializer) Single(doc Document) ([]byte, error) {
+	resource, err := s.resource(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(gin.H{"data": resource})
+}
+
+// Collection renders a document with the models as its primary data,
+// and the links as top-level links.
+func (s JSONAPISerializer) Collection(docs []Document, links []Link) ([]byte, error) {
+	data := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		resource, err := s.resource(doc)
+		if err != nil {
+			return nil, err
+		}
+		data[i] = resource
+	}
+
+	document := gin.H{"data": data}
+	if len(links) > 0 {
+		l := gin.H{}
+		for _, link := range links {
+			l[link.Rel] = link.Href
+		}
+		document["links"] = l
+	}
+	return json.Marshal(document)
+}
+
+// Error renders a document with an error object for each of the
+// fields of a ValidationError, or else a single error object. Other
+// members of the body (eg. `request_id`) are given as the error
+// objects' meta information.
+func (JSONAPISerializer) Error(status int, body interface{}) ([]byte, error) {
+	code := strconv.Itoa(status)
+
+	envelope, ok := body.(gin.H)
+	if !ok {
+		return json.Marshal(gin.H{"errors": []gin.H{{"status": code, "title": http.StatusText(status), "meta": gin.H{"error": body}}}})
+	}
+
+	title, _ := envelope["error"].(string)
+	meta := gin.H{}
+	for k, v := range envelope {
+		if k != "error" && k != "fields" {
+			meta[k] = v
+		}
+	}
+
+	errs := []gin.H{}
+	if fields, ok := envelope["fields"].([]FieldError); ok {
+		for _, fe := range fields {
+			e := gin.H{"status": code, "title": title, "code": fe.Rule, "detail": fe.Message}
+			if fe.Field != "" {
+				e["source"] = gin.H{"pointer": "/data/attributes/" + fe.Field}
+			}
+			if len(meta) > 0 {
+				e["meta"] = meta
+			}
+			errs = append(errs, e)
+		}
+	}
+	if len(errs) == 0 {
+		e := gin.H{"status": code, "title": title}
+		if len(meta) > 0 {
+			e["meta"] = meta
+		}
+		errs = append(errs, e)
+	}
+	return json.Marshal(gin.H{"errors": errs})
+}
+
+// MultiStatus renders a document with the results as its top-level
+// meta information, as JSON:API has no primary data for them.
+func (JSONAPISerializer) MultiStatus(results []BulkResult) ([]byte, error) {
+	return json.Marshal(gin.H{"meta": gin.H{"results": results}})
+}
+
+// HALSerializer renders documents as HAL resources
+// (https://tools.ietf.org/html/draft-kelly-json-hal), with models'
+// links as `self` links and collections as embedded resources keyed by
+// their type.
+type HALSerializer struct{}
+
+// ContentType is `application/hal+json`.
+func (HALSerializer) ContentType() string {
+	return "application/hal+json; charset=utf-8"
+}
+
+func (HALSerializer) resource(doc Document) (map[string]interface{}, error) {
+	resource, err := doc.fields()
+	if err != nil {
+		return nil, err
+	}
+	if doc.Link != "" {
+		resource["_links"] = gin.H{"self": gin.H{"href": doc.Link}}
+	}
+	return resource, nil
+}
+
+// Single renders the model's fields with its links.
+func (s HALSerializer) Single(doc Document) ([]byte, error) {
+	resource, err := s.resource(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resource)
+}
+
+// Collection renders a resource embedding the models, with the links
+// to other pages.
+func (s HALSerializer) Collection(docs []Document, links []Link) ([]byte, error) {
+	embedded := gin.H{}
+	for _, doc := range docs {
+		resource, err := s.resource(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		items, _ := embedded[doc.Type].([]interface{})
+		embedded[doc.Type] = append(items, resource)
+	}
+
+	l := gin.H{}
+	for _, link := range links {
+		l[link.Rel] = gin.H{"href": link.Href}
+	}
+	return json.Marshal(gin.H{"_embedded": embedded, "_links": l})
+}
+
+// Error renders the body as it is.
+func (HALSerializer) Error(status int, body interface{}) ([]byte, error) {
+	return json.Marshal(body)
+}
+
+// MultiStatus renders a resource embedding the results.
+func (HALSerializer) MultiStatus(results []BulkResult) ([]byte, error) {
+	return json.Marshal(gin.H{"_embedded": gin.H{"results": results}})
+}
+
+// mediaRange is a media range from an `Accept` header.
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept parses an `Accept` header into its media ranges, most
+// preferred first. Ranges with a quality of 0 aren't acceptable, and
+// are left out.
+func parseAccept(header string) []mediaRange {
+	ranges := []mediaRange{}
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		r := mediaRange{mediaType: strings.ToLower(strings.TrimSpace(params[0])), q: 1}
+		if r.mediaType == "" {
+			continue
+		}
+
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.ToLower(kv[0]) == "q" {
+				if q, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					r.q = q
+				}
+			}
+		}
+
+		if r.q > 0 {
+			ranges = append(ranges, r)
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	return ranges
+}
+
+// matches is true if `contentType` is in the media range.
+func (r mediaRange) matches(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch {
+	case r.mediaType == "*/*":
+		return true
+	case strings.HasSuffix(r.mediaType, "/*"):
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(r.mediaType, "*"))
+	default:
+		return r.mediaType == mediaType
+	}
+}
+
+// serializer returns the resource's serializer for the most preferred
+// media type accepted by the request, or ErrNotAcceptable. Requests
+// without an `Accept` header get the first serializer.
+func (h *handlers) serializer(ctx *gin.Context) (Serializer, error) {
+	if len(h.serializers) > 1 {
+		ctx.Header("Vary", "Accept")
+	}
+
+	accept := ctx.Request.Header.Get("Accept")
+	if accept == "" {
+		return h.serializers[0], nil
+	}
+
+	for _, r := range parseAccept(accept) {
+		for _, s := range h.serializers {
+			if r.matches(s.ContentType()) {
+				return s, nil
+			}
+		}
+	}
+	return nil, ErrNotAcceptable
+}
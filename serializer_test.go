@@ -0,0 +1,109 @@
+package resources_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theplant/resources"
+)
+
+func TestSerializers(t *testing.T) {
+	u, rs := createOwnedResources(t, 3)
+
+	serialized := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithLinker(func(id uint) string { return fmt.Sprintf("/r/%d", id) }),
+		resources.WithSerializers(resources.JSONSerializer{}, resources.JSONAPISerializer{}, resources.HALSerializer{}))
+
+	router = gin.New()
+	router.GET("/r", func(ctx *gin.Context) {
+		serialized.Collection(ctx, &u)
+	})
+	router.GET("/r/:id", serialized.ProvideModel(serialized.Get))
+	router.POST("/r", func(ctx *gin.Context) {
+		serialized.Post(ctx, &u, &u)
+	})
+
+	get := fmt.Sprintf("/r/%d", rs[0].ID)
+	tests := []struct {
+		Method string
+		Path   string
+		Accept string
+		Body   string
+		Code   int
+		// Content-Type of the response
+		ContentType string
+		// Top-level keys of the response body
+		Keys string
+	}{
+		{"GET", get, "", "", http.StatusOK, "application/json; charset=utf-8", "Count,CreatedAt,DeletedAt,ID,Text,UpdatedAt,User,UserID"},
+		{"GET", get, "*/*", "", http.StatusOK, "application/json; charset=utf-8", ""},
+		{"GET", get, "application/vnd.api+json", "", http.StatusOK, "application/vnd.api+json", "data"},
+		{"GET", get, "application/json;q=0.5, application/hal+json", "", http.StatusOK, "application/hal+json; charset=utf-8", "_links"},
+		{"GET", get, "text/html", "", http.StatusNotAcceptable, "application/json; charset=utf-8", "error"},
+		{"GET", "/r/0", "application/vnd.api+json", "", http.StatusNotFound, "application/vnd.api+json", "errors"},
+		{"GET", "/r?limit=2", "application/vnd.api+json", "", http.StatusOK, "application/vnd.api+json", "data,links"},
+		{"GET", "/r?limit=2", "application/hal+json", "", http.StatusOK, "application/hal+json; charset=utf-8", "_embedded,_links"},
+		{"POST", "/r", "application/vnd.api+json", `{"Count": 1}`, resources.HTTPStatusUnprocessableEntity, "application/vnd.api+json", "errors"},
+		{"POST", "/r", "text/html", `{"Text": "not created"}`, http.StatusNotAcceptable, "application/json; charset=utf-8", "error"},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(test.Method, test.Path, strings.NewReader(test.Body))
+		assertNoErr(err)
+		if test.Accept != "" {
+			req.Header.Set("Accept", test.Accept)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		if resp.Code != test.Code {
+			t.Fatalf("Error %s %s accepting '%s'\nexpected %d, got %d: %v", test.Method, test.Path, test.Accept, test.Code, resp.Code, resp)
+		}
+		if contentType := resp.Header().Get("Content-Type"); contentType != test.ContentType {
+			t.Fatalf("Wrong Content-Type in response to %s %s accepting '%s':\nexpected: '%v'\ngot:      '%v'", test.Method, test.Path, test.Accept, test.ContentType, contentType)
+		}
+
+		if test.Keys != "" {
+			var body map[string]interface{}
+			assertNoErr(json.Unmarshal(resp.Body.Bytes(), &body))
+			for _, key := range strings.Split(test.Keys, ",") {
+				if _, ok := body[key]; !ok {
+					t.Fatalf("Missing %s in response to %s %s accepting '%s': %v", key, test.Method, test.Path, test.Accept, resp.Body)
+				}
+			}
+		}
+	}
+
+	// Only the resource that was acceptable was created
+	count := 0
+	assertNoErr(db.Model(&Resource{}).Where("text = ?", "not created").Count(&count).Error)
+	if count != 0 {
+		t.Fatalf("Created resource with unacceptable response")
+	}
+
+	req, err := http.NewRequest("GET", get, nil)
+	assertNoErr(err)
+	req.Header.Set("Accept", "application/vnd.api+json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	var doc struct {
+		Data struct {
+			Type       string
+			ID         string
+			Attributes map[string]interface{}
+			Links      map[string]string
+		}
+	}
+	assertNoErr(json.Unmarshal(resp.Body.Bytes(), &doc))
+	if doc.Data.Type != "resources" || doc.Data.ID != fmt.Sprint(rs[0].ID) || doc.Data.Attributes["Text"] != "text" || doc.Data.Attributes["ID"] != nil || !strings.HasSuffix(doc.Data.Links["self"], get) {
+		t.Fatalf("Wrong JSON:API document: %v", resp.Body)
+	}
+}
@@ -0,0 +1,92 @@
+package resources
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// ErrInvalidDeleted is returned for a `deleted` parameter that isn't
+// `include` or `only`.
+var ErrInvalidDeleted = errors.New("deleted must be `include` or `only`")
+
+// deletedAtColumn is the column Gorm uses for soft-delete.
+const deletedAtColumn = "deleted_at"
+
+// checkDeleted panics if `opts` allow listing soft-deleted resources,
+// but the table of `scope` doesn't support soft-delete.
+func checkDeleted(scope *gorm.Scope, opts CollectionOptions) {
+	if !opts.Deleted {
+		return
+	}
+	if _, ok := scope.FieldByName(deletedAtColumn); !ok {
+		panic(fmt.Sprintf("resources: can't list deleted resources of %s without a %s column", scope.TableName(), deletedAtColumn))
+	}
+}
+
+// parseDeleted parses the `deleted` parameter, if allowed by `opts`,
+// into a scope that includes soft-deleted resources from the table of
+// `scope`, or only selects them. The parameter is removed from `query`, so that it isn't
+// parsed as a filter.
+func parseDeleted(query url.Values, scope *gorm.Scope, opts CollectionOptions) (func(*gorm.DB) *gorm.DB, error) {
+	if !opts.Deleted {
+		return func(db *gorm.DB) *gorm.DB { return db }, nil
+	}
+
+	deleted, ok := query["deleted"]
+	query.Del("deleted")
+	if !ok {
+		return func(db *gorm.DB) *gorm.DB { return db }, nil
+	}
+
+	switch deleted[0] {
+	case "include":
+		return func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		}, nil
+	case "only":
+		return func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Where(fmt.Sprintf("%s.%s IS NOT NULL", scope.QuotedTableName(), scope.Quote(deletedAtColumn)))
+		}, nil
+	}
+	return nil, ErrInvalidDeleted
+}
+
+// unscoped returns a copy of the handlers that also find, update and
+// delete soft-deleted models. Models are deleted permanently.
+func (h *handlers) unscoped() *handlers {
+	u := *h
+	u.db = h.db.Unscoped()
+	return &u
+}
+
+func (h *handlers) restore(ctx *gin.Context, s DBModel) {
+	if h.policy != nil {
+		if err := h.policy.CanUpdate(GetUser(ctx), s, GetParent(ctx)); err != nil {
+			h.abortWithError(ctx, err)
+			return
+		}
+	}
+
+	ifMatch, err := h.ifMatch(ctx)
+	if err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	if err := h.checkOutput(ctx); err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	if err := h.unscoped().update(ctx, s, ifMatch, map[string]interface{}{deletedAtColumn: nil}); err != nil {
+		h.abortWithError(ctx, err)
+		return
+	}
+
+	h.respondTagged(ctx, http.StatusOK, s)
+}
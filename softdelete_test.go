@@ -0,0 +1,63 @@
+package resources_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theplant/resources"
+)
+
+func TestSoftDelete(t *testing.T) {
+	u, rs := createOwnedResources(t, 3)
+	assertNoErr(db.Delete(&rs[1]).Error)
+	assertNoErr(db.Delete(&rs[2]).Error)
+
+	opts := resources.DefaultCollectionOptions
+	opts.Deleted = true
+
+	router = gin.New()
+	router.GET("/r", func(ctx *gin.Context) {
+		res.CollectionWith(opts)(ctx, &u)
+	})
+	router.GET("/undeletable", func(ctx *gin.Context) {
+		res.Collection(ctx, &u)
+	})
+	router.POST("/r/:id/restore", res.ProvideUnscopedModel(res.Restore))
+	router.DELETE("/r/:id/purge", res.ProvideUnscopedModel(res.Purge))
+	router.GET("/r/:id", res.ProvideModel(res.Get))
+
+	tests := []struct {
+		Method string
+		Path   string
+		Code   int
+		// IDs in a collection response
+		IDs []uint
+	}{
+		{"GET", "/r", http.StatusOK, []uint{rs[0].ID}},
+		{"GET", "/r?deleted=include", http.StatusOK, []uint{rs[0].ID, rs[1].ID, rs[2].ID}},
+		{"GET", "/r?deleted=only", http.StatusOK, []uint{rs[1].ID, rs[2].ID}},
+		{"GET", "/r?deleted=all", http.StatusBadRequest, nil},
+		{"GET", "/undeletable?deleted=only", http.StatusOK, []uint{rs[0].ID}},
+		{"POST", fmt.Sprintf("/r/%d/restore", rs[1].ID), http.StatusOK, nil},
+		{"GET", fmt.Sprintf("/r/%d", rs[1].ID), http.StatusOK, nil},
+		{"DELETE", fmt.Sprintf("/r/%d/purge", rs[2].ID), http.StatusNoContent, nil},
+		{"POST", fmt.Sprintf("/r/%d/restore", rs[2].ID), http.StatusNotFound, nil},
+		{"GET", "/r?deleted=include", http.StatusOK, []uint{rs[0].ID, rs[1].ID}},
+	}
+
+	for _, test := range tests {
+		resp := doRequest(t, test.Method, test.Path, nil)
+		if resp.Code != test.Code {
+			t.Fatalf("Error %s %s\nexpected %d, got %d: %v", test.Method, test.Path, test.Code, resp.Code, resp)
+		}
+
+		if test.IDs != nil {
+			if got := ids(unmarshalCollection(t, resp)); !equalIDs(got, test.IDs) {
+				t.Fatalf("Wrong resources in response to %s %s\nexpected: '%v'\ngot:      '%v'", test.Method, test.Path, test.IDs, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package resources
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ErrCursorWithSort is returned when a request asks for keyset
+// (`cursor`) pagination of a sorted collection. Cursors only work
+// with the default primary key ordering.
+var ErrCursorWithSort = errors.New("cursor and sort can't be used together")
+
+// SortError is returned for a `sort` parameter that names a column
+// that the collection can't be sorted by.
+type SortError struct {
+	Field string
+}
+
+func (err SortError) Error() string {
+	return fmt.Sprintf("can't sort by %q", err.Field)
+}
+
+// ordering is a single column of a `sort` parameter.
+type ordering struct {
+	column string
+	desc   bool
+}
+
+// parseSort parses a `sort` parameter like `-created_at,text` into
+// orderings on the `sortable` columns. A leading `-` sorts by the
+// column in descending order.
+func parseSort(query url.Values, sortable []string) ([]ordering, error) {
+	s := query.Get("sort")
+	if s == "" {
+		return nil, nil
+	}
+
+	orderings := []ordering{}
+	for _, field := range strings.Split(s, ",") {
+		o := ordering{column: strings.TrimPrefix(field, "-"), desc: strings.HasPrefix(field, "-")}
+		if !contains(sortable, o.column) {
+			return nil, SortError{Field: o.column}
+		}
+		orderings = append(orderings, o)
+	}
+	return orderings, nil
+}
+
+// sortScope returns a Gorm scope that orders a query on the table of
+// `scope` by `orderings`. The primary key `pk` is always added as the
+// final ordering, so that results are in a stable order.
+func sortScope(scope *gorm.Scope, orderings []ordering, pk string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, o := range orderings {
+			dir := "ASC"
+			if o.desc {
+				dir = "DESC"
+			}
+			db = db.Order(fmt.Sprintf("%s.%s %s", scope.QuotedTableName(), scope.Quote(o.column), dir))
+		}
+		return db.Order(pk + " ASC")
+	}
+}
+
+// checkSortable panics if `sortable` refers to columns that aren't in
+// the table of `scope`.
+func checkSortable(scope *gorm.Scope, sortable []string) {
+	for _, column := range sortable {
+		if !hasColumn(scope, column) {
+			panic(fmt.Sprintf("resources: can't sort by unknown column %q of %s", column, scope.TableName()))
+		}
+	}
+}
+
+func contains(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
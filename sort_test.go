@@ -0,0 +1,57 @@
+package resources_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/theplant/resources"
+)
+
+func TestCollectionSort(t *testing.T) {
+	u, rs := createOwnedResources(t, 0)
+	for _, text := range []string{"b", "a", "b", "c"} {
+		r := Resource{UserID: u.ID, Text: text}
+		assertNoErr(db.Save(&r).Error)
+		rs = append(rs, r)
+	}
+
+	collection := res.CollectionWith(resources.CollectionOptions{
+		DefaultLimit: 10,
+		Sortable:     []string{"text", "created_at"},
+	})
+	req := mountOwnerHandler(t, &u, collection)
+
+	tests := []struct {
+		Query string
+		IDs   []uint
+	}{
+		{"", ids(rs)},
+		{"sort=text", []uint{rs[1].ID, rs[0].ID, rs[2].ID, rs[3].ID}},
+		{"sort=-text", []uint{rs[3].ID, rs[0].ID, rs[2].ID, rs[1].ID}},
+		{"sort=-text,-created_at", []uint{rs[3].ID, rs[2].ID, rs[0].ID, rs[1].ID}},
+		{"sort=text&limit=2&offset=2", []uint{rs[2].ID, rs[3].ID}},
+	}
+
+	for _, test := range tests {
+		resp := req(test.Query)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("Error sorting collection with %q\nexpected %d, got %d: %v", test.Query, http.StatusOK, resp.Code, resp)
+		}
+
+		got := ids(unmarshalCollection(t, resp))
+		if !equalIDs(got, test.IDs) {
+			t.Fatalf("Wrong order sorting with %q\nexpected: '%v'\ngot:      '%v'", test.Query, test.IDs, got)
+		}
+	}
+
+	if prev := linkHeader(req("sort=text&limit=2&offset=2"))["prev"]; prev == nil || prev.Query().Get("sort") != "text" {
+		t.Fatalf("Sort missing from prev link, got: '%v'", prev)
+	}
+
+	for _, query := range []string{"sort=user_id", "sort=text,", "sort=text&cursor="} {
+		resp := req(query)
+		if resp.Code != http.StatusBadRequest {
+			t.Fatalf("Error sorting collection with %q\nexpected %d, got %d: %v", query, http.StatusBadRequest, resp.Code, resp)
+		}
+	}
+}
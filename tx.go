@@ -0,0 +1,106 @@
+package resources
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+const txKey = "resources.tx"
+
+// GetTx returns the transaction that the handler of the request is
+// running in (see WithTransactions), or nil. Hooks should use it for
+// their own queries, so that they're rolled back with the handler's
+// changes.
+func GetTx(ctx *gin.Context) *gorm.DB {
+	if tx, ok := ctx.Get(txKey); ok {
+		if tx, ok := tx.(*gorm.DB); ok {
+			return tx
+		}
+	}
+	return nil
+}
+
+// bufferedWriter holds back the body of a handler's response until
+// the handler's transaction has been committed, so that a failed
+// commit can be responded to instead. Gin's own writer only records
+// the status until the body is written.
+type bufferedWriter struct {
+	gin.ResponseWriter
+
+	body bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// flush writes the response to the underlying writer.
+func (w *bufferedWriter) flush() {
+	w.ResponseWriter.WriteHeaderNow()
+	w.ResponseWriter.Write(w.body.Bytes())
+}
+
+// transaction calls `handler` in a transaction (see inTransaction), if
+// the resource was created with WithTransactions, or else with `h`.
+func (h *handlers) transaction(ctx *gin.Context, handler func(*handlers)) {
+	if !h.transactions {
+		handler(h)
+		return
+	}
+	h.inTransaction(ctx, handler)
+}
+
+// inTransaction calls `handler` with a copy of the handlers that use a
+// new transaction. The transaction is committed if the handler
+// responds with a success, and rolled back if it responds with an
+// error or panics.
+func (h *handlers) inTransaction(ctx *gin.Context, handler func(*handlers)) {
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		h.abortWithError(ctx, tx.Error)
+		return
+	}
+
+	writer := ctx.Writer
+	buffered := &bufferedWriter{ResponseWriter: writer}
+	ctx.Writer = buffered
+	ctx.Set(txKey, tx)
+
+	committed := false
+	defer func() {
+		ctx.Writer = writer
+		ctx.Set(txKey, nil)
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	inTx := *h
+	inTx.db = tx
+	handler(&inTx)
+
+	ctx.Writer = writer
+	if writer.Status() >= http.StatusBadRequest {
+		buffered.flush()
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		for _, header := range []string{"Location", "ETag", "Last-Modified"} {
+			writer.Header().Del(header)
+		}
+		h.abortWithError(ctx, err)
+		return
+	}
+	committed = true
+	buffered.flush()
+}
@@ -0,0 +1,86 @@
+package resources_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theplant/resources"
+)
+
+func TestTransactions(t *testing.T) {
+	u := User{}
+	assertNoErr(db.Save(&u).Error)
+
+	errAfterCreate := errors.New("after create failed")
+	transactional := resources.NewWithOptions(db,
+		func() resources.DBModel { return &Resource{} },
+		resources.WithTransactions(),
+		resources.WithErrorMapper(resources.MapErrorIs(errAfterCreate, http.StatusConflict)),
+		resources.WithHooks(resources.Hooks{
+			AfterCreate: func(ctx *gin.Context, s resources.DBModel, user resources.User, parent resources.DBModel) error {
+				if resources.GetTx(ctx) == nil {
+					t.Fatalf("Hook not given transaction")
+				}
+
+				switch s.(*Resource).Text {
+				case "fail":
+					return errAfterCreate
+				case "panic":
+					panic("after create panicked")
+				}
+				return nil
+			},
+			AfterUpdate: func(ctx *gin.Context, old resources.DBModel, updated resources.DBModel) error {
+				// Changes made with the transaction are rolled back too
+				assertNoErr(resources.GetTx(ctx).Model(&u).Update("updated_at", nil).Error)
+				return errAfterCreate
+			},
+		}))
+
+	router = gin.New()
+	router.Use(gin.Recovery())
+	router.POST("/r", func(ctx *gin.Context) {
+		transactional.Post(ctx, &u, &u)
+	})
+	router.PATCH("/r/:id", transactional.ProvideModel(transactional.Patch))
+
+	tests := []struct {
+		Text string
+		Code int
+	}{
+		{"fail", http.StatusConflict},
+		{"panic", http.StatusInternalServerError},
+		{"created", http.StatusCreated},
+	}
+
+	for _, test := range tests {
+		resp := doRequest(t, "POST", "/r", strings.NewReader(fmt.Sprintf(`{"Text": %q}`, test.Text)))
+		if resp.Code != test.Code {
+			t.Fatalf("Error POSTing %s\nexpected %d, got %d: %v", test.Text, test.Code, resp.Code, resp)
+		}
+	}
+
+	created := []Resource{}
+	assertNoErr(db.Where("user_id = ?", u.ID).Find(&created).Error)
+	if len(created) != 1 || created[0].Text != "created" {
+		t.Fatalf("Rows left behind by failed transactions: %v", created)
+	}
+
+	resp := doRequest(t, "PATCH", fmt.Sprintf("/r/%d", created[0].ID), strings.NewReader(`{"Text": "changed"}`))
+	if resp.Code != http.StatusConflict {
+		t.Fatalf("Error PATCHing with failing hook\nexpected %d, got %d: %v", http.StatusConflict, resp.Code, resp)
+	}
+
+	reloaded := &Resource{}
+	assertNoErr(db.Where("id = ?", created[0].ID).Find(reloaded).Error)
+	owner := &User{}
+	assertNoErr(db.Where("id = ?", u.ID).Find(owner).Error)
+	if reloaded.Text != "created" || owner.UpdatedAt.IsZero() {
+		t.Fatalf("Failed PATCH not rolled back: '%v', %v", reloaded.Text, owner.UpdatedAt)
+	}
+}
@@ -0,0 +1,188 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/go-playground/validator.v8"
+)
+
+// FieldError describes why a single field of a request body is
+// invalid.
+type FieldError struct {
+	// Field is the path to the field in the JSON body, eg. `text` or
+	// `user.name`. It is empty for errors with the body as a whole.
+	Field string `json:"field"`
+
+	// Rule is the validation rule that failed, eg. `required` or
+	// `max`, or `syntax` or `type` for JSON decoding errors.
+	Rule string `json:"rule"`
+
+	// Param is the parameter of the failed rule, eg. the maximum
+	// length for `max`, or the expected type for `type`.
+	Param string `json:"param,omitempty"`
+
+	Message string `json:"message"`
+}
+
+// ValidationError is the error responded with when a request body
+// can't be bound to a resource. Handlers respond to it with
+// HTTPStatusUnprocessableEntity, listing each invalid field in the
+// `fields` key.
+//
+// It wraps ErrRequestMissingAttrs, so `errors.Is(err,
+// ErrRequestMissingAttrs)` is true for any ValidationError.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (err *ValidationError) Error() string {
+	return ErrRequestMissingAttrs.Error()
+}
+
+// Unwrap returns ErrRequestMissingAttrs.
+func (err *ValidationError) Unwrap() error {
+	return ErrRequestMissingAttrs
+}
+
+var ruleMessages = map[string]string{
+	"required": "is required",
+	"max":      "must be at most %s",
+	"min":      "must be at least %s",
+	"len":      "must have length %s",
+	"eq":       "must be equal to %s",
+	"ne":       "must not be equal to %s",
+	"lt":       "must be less than %s",
+	"lte":      "must be at most %s",
+	"gt":       "must be greater than %s",
+	"gte":      "must be at least %s",
+	"oneof":    "must be one of %s",
+	"email":    "must be an email address",
+	"url":      "must be a URL",
+}
+
+// newValidationError converts an error from binding a request body
+// to `model` into a ValidationError, naming fields by their JSON
+// names.
+func newValidationError(err error, model interface{}) *ValidationError {
+	switch err := err.(type) {
+	case *ValidationError:
+		return err
+	case validator.ValidationErrors:
+		fields := []FieldError{}
+		for _, fe := range err {
+			fields = append(fields, fieldError(fe, model))
+		}
+		sort.Slice(fields, func(i, j int) bool {
+			return fields[i].Field < fields[j].Field
+		})
+		return &ValidationError{Fields: fields}
+	case *json.SyntaxError:
+		return &ValidationError{Fields: []FieldError{{
+			Rule:    "syntax",
+			Message: fmt.Sprintf("invalid JSON at offset %d: %s", err.Offset, err.Error()),
+		}}}
+	case *json.UnmarshalTypeError:
+		field := jsonPath(reflect.TypeOf(model), strings.Split(err.Field, "."))
+		return &ValidationError{Fields: []FieldError{{
+			Field:   field,
+			Rule:    "type",
+			Param:   err.Type.String(),
+			Message: fmt.Sprintf("must be %s, not %s", err.Type.String(), err.Value),
+		}}}
+	}
+
+	switch err {
+	case io.EOF:
+		return &ValidationError{Fields: []FieldError{{
+			Rule:    "required",
+			Message: "request body is empty",
+		}}}
+	case io.ErrUnexpectedEOF:
+		return &ValidationError{Fields: []FieldError{{
+			Rule:    "syntax",
+			Message: "unexpected end of JSON input",
+		}}}
+	}
+
+	return &ValidationError{Fields: []FieldError{}}
+}
+
+func fieldError(fe *validator.FieldError, model interface{}) FieldError {
+	// The namespace starts with the name of the validated struct
+	path := strings.Split(fe.FieldNamespace, ".")
+	if len(path) > 1 {
+		path = path[1:]
+	}
+	field := jsonPath(reflect.TypeOf(model), path)
+
+	msg, ok := ruleMessages[fe.Tag]
+	if !ok {
+		msg = "failed on the '" + fe.Tag + "' rule"
+	} else if strings.Contains(msg, "%s") {
+		msg = fmt.Sprintf(msg, fe.Param)
+	}
+
+	return FieldError{
+		Field:   field,
+		Rule:    fe.Tag,
+		Param:   fe.Param,
+		Message: msg,
+	}
+}
+
+// jsonPath converts a path of Go struct field names (possibly with
+// slice indexes, like `Items[0]`) through `t` into the path of the
+// same field in the JSON encoding of `t`.
+func jsonPath(t reflect.Type, path []string) string {
+	names := []string{}
+	for _, segment := range path {
+		if segment == "" {
+			continue
+		}
+
+		index := ""
+		if i := strings.Index(segment, "["); i >= 0 {
+			segment, index = segment[:i], segment[i:]
+		}
+
+		for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map) {
+			t = t.Elem()
+		}
+
+		name := segment
+		if t != nil && t.Kind() == reflect.Struct {
+			if f, ok := t.FieldByName(segment); ok {
+				t = f.Type
+				jsonName, _ := jsonFieldName(f)
+				if f.Anonymous && jsonName == f.Name {
+					// Embedded structs are flattened in JSON
+					continue
+				}
+				name = jsonName
+			} else {
+				t = nil
+			}
+		}
+
+		names = append(names, name+index)
+	}
+	return strings.Join(names, ".")
+}
+
+// jsonFieldName returns the name used for `f` in JSON, and whether
+// the field is included in JSON at all.
+func jsonFieldName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name, true
+	}
+	return f.Name, true
+}
@@ -0,0 +1,102 @@
+package resources_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/theplant/resources"
+)
+
+func TestValidationErrors(t *testing.T) {
+	u := User{}
+	assertNoErr(db.Save(&u).Error)
+
+	r := Resource{}
+	assertNoErr(db.Save(&r).Error)
+
+	tests := []struct {
+		// Methods the body is invalid for
+		Methods  []string
+		Body     string
+		Expected resources.FieldError
+	}{
+		// Required fields can be left out of PATCH bodies
+		{[]string{"POST"}, `{}`, resources.FieldError{Field: "Text", Rule: "required", Message: "is required"}},
+		{[]string{"POST", "PATCH"}, `{"Text": ""}`, resources.FieldError{Field: "Text", Rule: "required", Message: "is required"}},
+		{[]string{"POST", "PATCH"}, `{"Text": 5}`, resources.FieldError{Field: "Text", Rule: "type", Param: "string", Message: "must be string, not number"}},
+		{[]string{"POST", "PATCH"}, `{"Text": "text"`, resources.FieldError{Rule: "syntax"}},
+		{[]string{"POST", "PATCH"}, ``, resources.FieldError{Rule: "required", Message: "request body is empty"}},
+	}
+
+	for _, test := range tests {
+		// Handlers are mounted on the shared router when requested
+		reqs := map[string]func(string) *httptest.ResponseRecorder{
+			"POST": func(body string) *httptest.ResponseRecorder {
+				return mountOwnerParentHandler(t, &u, &u, res.Post)(strings.NewReader(body))
+			},
+			"PATCH": func(body string) *httptest.ResponseRecorder {
+				return mountResourceHandler(t, &r, res.Patch)(strings.NewReader(body))
+			},
+		}
+
+		for method, req := range reqs {
+			if !containsString(test.Methods, method) {
+				if resp := req(test.Body); resp.Code != http.StatusOK {
+					t.Fatalf("Error %sing %q\nexpected %d, got %d: %v", method, test.Body, http.StatusOK, resp.Code, resp)
+				}
+				continue
+			}
+
+			result := unmarshalValidation(t, req(test.Body))
+
+			if result.Code != resources.HTTPStatusUnprocessableEntity {
+				t.Fatalf("Error %sing %q\nexpected %d, got %d", method, test.Body, resources.HTTPStatusUnprocessableEntity, result.Code)
+			}
+
+			if result.Error != resources.ErrRequestMissingAttrs.Error() {
+				t.Fatalf("Wrong error %sing %q\nexpected: '%v'\ngot:      '%v'", method, test.Body, resources.ErrRequestMissingAttrs, result.Error)
+			}
+
+			if len(result.Fields) != 1 {
+				t.Fatalf("Wrong fields %sing %q\nexpected: '%v'\ngot:      '%v'", method, test.Body, test.Expected, result.Fields)
+			}
+
+			got := result.Fields[0]
+			if test.Expected.Rule == "syntax" {
+				// Syntax error messages come from encoding/json
+				got.Message = ""
+			}
+			if got != test.Expected {
+				t.Fatalf("Wrong field error %sing %q\nexpected: '%v'\ngot:      '%v'", method, test.Body, test.Expected, got)
+			}
+		}
+	}
+}
+
+type validationResponse struct {
+	Code   int
+	Error  string
+	Fields []resources.FieldError
+}
+
+func unmarshalValidation(t *testing.T, res *httptest.ResponseRecorder) validationResponse {
+	b, err := ioutil.ReadAll(res.Body)
+	assertNoErr(err)
+
+	result := validationResponse{Code: res.Code}
+	assertNoErr(json.Unmarshal(b, &result))
+	return result
+}
+
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package resources
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FieldMode is how handlers treat fields of a request body that
+// can't be written.
+type FieldMode int
+
+const (
+	// RejectFields responds with a ValidationError listing the
+	// fields. It is the default.
+	RejectFields FieldMode = iota
+
+	// DropFields ignores the fields, as if they weren't in the body.
+	DropFields
+)
+
+// Values of the `resources` struct tag.
+const (
+	// tagReadOnly fields can't be written by requests.
+	tagReadOnly = "readonly"
+
+	// tagCreateOnly fields can only be written when the model is
+	// created.
+	tagCreateOnly = "createonly"
+)
+
+// unwritable returns the rule that stops `f` of `s` being written by
+// the user of the request when taking `action` (ActionCreate or
+// ActionUpdate), or "" if it can be written.
+//
+// The primary key, Gorm's timestamps and the version column (see
+// WithVersionColumn) can never be written, and the columns set by
+// SetOwner and SetParent can't be updated.
+func (h *handlers) unwritable(ctx *gin.Context, s DBModel, f modelField, action Action) string {
+	if f.IsPrimaryKey || f.DBName == "created_at" || f.DBName == updatedAtColumn || f.DBName == "deleted_at" {
+		return tagReadOnly
+	}
+	if h.versionColumn != "" && f.DBName == h.versionColumn {
+		return tagReadOnly
+	}
+	if action == ActionUpdate && setsOwner(s, f) {
+		return tagReadOnly
+	}
+
+	tags := strings.Split(f.Tag.Get("resources"), ",")
+	if contains(tags, tagReadOnly) {
+		return tagReadOnly
+	}
+	if action == ActionUpdate && contains(tags, tagCreateOnly) {
+		return tagCreateOnly
+	}
+
+	whitelist := h.updateFields
+	if action == ActionCreate {
+		whitelist = h.createFields
+	}
+	if whitelist != nil && !contains(whitelist, f.jsonName) {
+		return tagReadOnly
+	}
+
+	if policy, ok := h.policy.(FieldPolicy); ok {
+		if writable := policy.WritableFields(GetUser(ctx)); writable != nil && !contains(writable, f.jsonName) {
+			return "forbidden"
+		}
+	}
+
+	return ""
+}
+
+// writable checks the keys of the JSON object `body` against the
+// fields of `s` that can be written when taking `action`, and
+// returns the body with any unknown or unwritable keys dropped, or a
+// ValidationError listing them, depending on the resource's
+// FieldMode.
+//
+// Bodies that aren't JSON objects are returned unchanged, for binding
+// to report.
+func (h *handlers) writable(ctx *gin.Context, s DBModel, action Action, body []byte) ([]byte, error) {
+	keys := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return body, nil
+	}
+
+	fields := columnFields(h.db, s)
+
+	invalid := []FieldError{}
+	for key := range keys {
+		f, ok := fieldForKey(fields, key)
+		if !ok {
+			invalid = append(invalid, FieldError{Field: key, Rule: "unknown", Message: "is not a field of this resource"})
+			continue
+		}
+
+		switch rule := h.unwritable(ctx, s, f, action); rule {
+		case "":
+		case "forbidden":
+			invalid = append(invalid, FieldError{Field: key, Rule: rule, Message: "can't be written by this user"})
+		case tagCreateOnly:
+			invalid = append(invalid, FieldError{Field: key, Rule: rule, Message: "can only be set when created"})
+		default:
+			invalid = append(invalid, FieldError{Field: key, Rule: rule, Message: "is read-only"})
+		}
+	}
+
+	if len(invalid) == 0 {
+		return body, nil
+	}
+
+	if h.fieldMode == DropFields {
+		for _, fe := range invalid {
+			delete(keys, fe.Field)
+		}
+		return json.Marshal(keys)
+	}
+
+	sort.Slice(invalid, func(i, j int) bool {
+		return invalid[i].Field < invalid[j].Field
+	})
+	return nil, &ValidationError{Fields: invalid}
+}
+
+// writableRequest applies `writable` to the request body, leaving the
+// result to be bound. If the request replaces `stored` (which is nil
+// otherwise), keys that can't be written are first dropped if they
+// are unchanged (see unchanged).
+func (h *handlers) writableRequest(ctx *gin.Context, s DBModel, action Action, stored DBModel) error {
+	if ctx.Request.Body == nil {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		return err
+	}
+
+	if stored != nil {
+		if body, err = h.unchanged(ctx, stored, body); err != nil {
+			return err
+		}
+	}
+	if body, err = h.writable(ctx, s, action, body); err != nil {
+		return err
+	}
+	ctx.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+// unchanged drops the keys of the JSON object `body` that can't be
+// updated (including associations and other keys that aren't fields)
+// but have the same values as in `stored`, so that Put accepts the
+// representation given by Get. Only changes to them are rejected.
+// Keys that can be updated are left, as Put zeroes fields left out.
+func (h *handlers) unchanged(ctx *gin.Context, stored DBModel, body []byte) ([]byte, error) {
+	keys := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return body, nil
+	}
+
+	encoded, err := json.Marshal(stored)
+	if err != nil {
+		return nil, err
+	}
+	current := map[string]interface{}{}
+	if err := decodeJSON(encoded, &current); err != nil {
+		return nil, err
+	}
+
+	fields := columnFields(h.db, stored)
+
+	dropped := false
+	for key, raw := range keys {
+		if f, ok := fieldForKey(fields, key); ok && h.unwritable(ctx, stored, f, ActionUpdate) == "" {
+			continue
+		}
+
+		value, ok := current[key]
+		if !ok {
+			for name, v := range current {
+				if strings.EqualFold(name, key) {
+					value, ok = v, true
+					break
+				}
+			}
+		}
+
+		var given interface{}
+		if !ok || decodeJSON(raw, &given) != nil || !reflect.DeepEqual(given, value) {
+			continue
+		}
+		delete(keys, key)
+		dropped = true
+	}
+
+	if !dropped {
+		return body, nil
+	}
+	return json.Marshal(keys)
+}
+
+// writableColumns removes the columns of fields that can't be updated
+// from `columns`, which is keyed by DB column, so that Put leaves
+// them unchanged.
+func (h *handlers) writableColumns(ctx *gin.Context, s DBModel, columns map[string]interface{}) map[string]interface{} {
+	for _, f := range columnFields(h.db, s) {
+		if h.unwritable(ctx, s, f, ActionUpdate) != "" {
+			delete(columns, f.DBName)
+		}
+	}
+	return columns
+}
+
+// setsOwner is true if `f` is a column set by SetOwner or SetParent of
+// `s`, ie. changing it changes the OwnerID or ParentID of `s`.
+func setsOwner(s DBModel, f modelField) bool {
+	probe := copyModel(s)
+	v := reflect.Indirect(reflect.ValueOf(probe)).FieldByName(f.Name)
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(v.Uint() + 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(v.Int() + 1)
+	default:
+		return false
+	}
+	return probe.OwnerID() != s.OwnerID() || probe.ParentID() != s.ParentID()
+}
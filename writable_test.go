@@ -0,0 +1,93 @@
+package resources_test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/theplant/resources"
+)
+
+type taggedResource struct {
+	Resource
+
+	Code  string `resources:"createonly"`
+	Notes string `resources:"readonly"`
+}
+
+func TestUnwritableFields(t *testing.T) {
+	assertNoErr(db.DropTableIfExists(&taggedResource{}).Error)
+	assertNoErr(db.AutoMigrate(&taggedResource{}).Error)
+
+	u := User{}
+	assertNoErr(db.Save(&u).Error)
+	r := taggedResource{Resource: Resource{Text: "original", UserID: u.ID}, Code: "code", Notes: "notes"}
+	assertNoErr(db.Save(&r).Error)
+
+	single := func() resources.DBModel { return &taggedResource{} }
+	tests := []struct {
+		Options []resources.Option
+		Method  string
+		Body    string
+		Code    int
+		Fields  string
+	}{
+		{nil, "POST", `{"Text": "created", "Code": "new"}`, http.StatusCreated, ""},
+		{nil, "POST", `{"Text": "created", "Notes": "new"}`, resources.HTTPStatusUnprocessableEntity, "Notes"},
+		{nil, "POST", `{"Text": "created", "ID": 1000, "CreatedAt": "2000-01-01T00:00:00Z", "Unknown": 1}`, resources.HTTPStatusUnprocessableEntity, "CreatedAt,ID,Unknown"},
+		{nil, "PATCH", `{"Code": "changed", "DeletedAt": "2000-01-01T00:00:00Z"}`, resources.HTTPStatusUnprocessableEntity, "Code,DeletedAt"},
+		{nil, "PATCH", `{"Text": "changed"}`, http.StatusOK, ""},
+		{nil, "POST", `{"text": "created", "code": "lower case"}`, http.StatusCreated, ""},
+		{nil, "PATCH", `{"notes": "changed"}`, resources.HTTPStatusUnprocessableEntity, "notes"},
+		{nil, "PATCH", fmt.Sprintf(`{"UserID": %d}`, u.ID+1), resources.HTTPStatusUnprocessableEntity, "UserID"},
+		{[]resources.Option{resources.WithUpdateFields("Count")}, "PATCH", `{"Text": "whitelisted"}`, resources.HTTPStatusUnprocessableEntity, "Text"},
+		{[]resources.Option{resources.WithCreateFields("Text")}, "POST", `{"Text": "created", "Count": 1}`, resources.HTTPStatusUnprocessableEntity, "Count"},
+		{[]resources.Option{resources.WithUnwritableFields(resources.DropFields)}, "POST", `{"Text": "dropped", "Notes": "new", "ID": 1000}`, http.StatusCreated, ""},
+		{[]resources.Option{resources.WithUnwritableFields(resources.DropFields)}, "PATCH", `{"Count": 2, "Code": "changed", "Notes": "changed"}`, http.StatusOK, ""},
+	}
+
+	for _, test := range tests {
+		tagged := resources.NewWithOptions(db, single, test.Options...)
+
+		router = gin.New()
+		router.POST("/r", func(ctx *gin.Context) {
+			tagged.Post(ctx, &u, &u)
+		})
+		router.PATCH("/r/:id", tagged.ProvideModel(tagged.Patch))
+
+		path := "/r"
+		if test.Method == "PATCH" {
+			path = fmt.Sprintf("/r/%d", r.ID)
+		}
+		resp := doRequest(t, test.Method, path, strings.NewReader(test.Body))
+
+		if resp.Code != test.Code {
+			t.Fatalf("Error %s %s\nexpected %d, got %d: %v", test.Method, test.Body, test.Code, resp.Code, resp)
+		}
+
+		if test.Fields != "" {
+			fields := []string{}
+			for _, fe := range unmarshalValidation(t, resp).Fields {
+				fields = append(fields, fe.Field)
+			}
+			if strings.Join(fields, ",") != test.Fields {
+				t.Fatalf("Wrong unwritable fields in response to %s %s:\nexpected: '%v'\ngot:      '%v'", test.Method, test.Body, test.Fields, fields)
+			}
+		}
+	}
+
+	created := []taggedResource{}
+	assertNoErr(db.Where("user_id = ?", u.ID).Order("id").Find(&created).Error)
+	if len(created) != 4 || created[1].Code != "new" || created[2].Code != "lower case" || created[3].Text != "dropped" || created[3].Notes != "" || created[3].ID == 1000 {
+		t.Fatalf("Wrong resources created: %v", created)
+	}
+
+	reloaded := &taggedResource{}
+	assertNoErr(db.Where("id = ?", r.ID).Find(reloaded).Error)
+	if reloaded.Text != "changed" || reloaded.Count != 2 || reloaded.Code != "code" || reloaded.Notes != "notes" {
+		t.Fatalf("Wrong result of PATCHes: %v", reloaded)
+	}
+}